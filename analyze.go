@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/analyze"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+	flag "github.com/spf13/pflag"
+)
+
+func analyzeUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: %s analyze [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Parse metrics files (like drivers, without load) and print metrics count, tagged rows,\n")
+	fmt.Fprintf(os.Stderr, "top tag keys by cardinality, longest paths and metrics with most tags.\n")
+	fmt.Fprintf(os.Stderr, "Tagged rows are counted with --tag1-keys policy (all tags by default).\n")
+	fmt.Fprintf(os.Stderr, "Metrics are transformed, filtered and checked by limits with the same flags as for load.\n\n")
+	fs.PrintDefaults()
+}
+
+// analyzeMain is an analyze subcommand, return exit code
+func analyzeMain(args []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	fs.Usage = func() { analyzeUsage(fs) }
+	var fileNames StringSlice
+	fs.VarP(&fileNames, "file", "f", "metrics file")
+	var tagsMode tags.Mode
+	fs.Var(&tagsMode, "tags-mode", "tagged path mode: natural or graphite")
+	var tag1Keys StringSlice
+	fs.Var(&tag1Keys, "tag1-keys", "count Tag1 rows only for __name__ and these tag keys (default all tags), like for load")
+	var pipelineFlags PipelineFlags
+	pipelineFlags.Register(fs)
+	top := fs.IntP("top", "n", 10, "top lists size")
+	format := fs.StringP("format", "F", "text", "output format (text, json)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if len(fileNames) == 0 || fs.NArg() > 0 {
+		analyzeUsage(fs)
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "invalid format: %s\n", *format)
+		return 2
+	}
+
+	pipeline, err := pipelineFlags.Pipeline()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	guard := pipelineFlags.Guard()
+	date := time.Now()
+
+	a := analyze.New(tagsMode.Parser(), *top)
+	a.SetTag1Policy(driver.NewTag1Policy(tag1Keys))
+	for _, filename := range fileNames {
+		r, err := openFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		err = readLines(r, func(line string) error {
+			// invalid and dropped metrics are counted in report
+			metric, ok, err := pipeline.Process(line)
+			if err != nil {
+				a.AddInvalid()
+				return nil
+			}
+			if !ok {
+				a.AddFiltered()
+				return nil
+			}
+			if guard != nil && strings.Contains(metric, ";") {
+				if err = guard.Check(metric, date); err != nil {
+					a.AddRejected()
+					return nil
+				}
+			}
+			a.Add(metric)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return 1
+		}
+	}
+
+	report := a.Report()
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -3,35 +3,19 @@ package main
 import (
 	"fmt"
 	"strings"
-)
-
-type ChDriver int16
 
-const (
-	ChDriverRowBinary ChDriver = iota
-	ChDriverMailRu
-	ChDriverStd    // clickhouse-go with database/sql interface
-	ChDriverNative // clickhouse-go with native interface
-	ChDriverCol    // columnar interface with github.com/vahid-sohrabloo/chconn
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
 )
 
-var driverStrings []string = []string{"rowbin", "mail.ru", "std", "native", "columnar"}
+// ChDriver is a name of driver, registered in pkg/driver registry
+type ChDriver string
 
 func (a *ChDriver) Set(value string) error {
-	switch value {
-	case "rowbin", "rowbinary":
-		*a = ChDriverRowBinary
-	case "mail.ru":
-		*a = ChDriverMailRu
-	case "std":
-		*a = ChDriverStd
-	case "native":
-		*a = ChDriverNative
-	case "columnar":
-		*a = ChDriverCol
-	default:
+	r, err := driver.Lookup(value)
+	if err != nil {
 		return fmt.Errorf("invalid clickhouse driver %s", value)
 	}
+	*a = ChDriver(r.Name)
 	return nil
 }
 
@@ -50,7 +34,7 @@ func (a *ChDriver) UnmarshalYAML(unmarshal func(interface{}) error) error {
 }
 
 func (a *ChDriver) String() string {
-	return driverStrings[*a]
+	return string(*a)
 }
 
 func (a *ChDriver) Type() string {
@@ -58,7 +42,12 @@ func (a *ChDriver) Type() string {
 }
 
 func (a *ChDriver) Drivers() string {
-	return "[" + strings.Join(driverStrings, ",") + "]"
+	regs := driver.Registrations()
+	drivers := make([]string, 0, len(regs))
+	for _, r := range regs {
+		drivers = append(drivers, r.Name+"("+r.Caps.String()+")")
+	}
+	return "[" + strings.Join(drivers, ",") + "]"
 }
 
 type StringSlice []string
@@ -2,16 +2,14 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"sync"
 
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
-	driver_col "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/columnar"
-	driver_mail_ru "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/mail_ru"
-	driver_native "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/native"
-	driver_rowbin "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/rowbin"
-	driver_std "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/std"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/limits"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
 	"github.com/tevino/abool/v2"
 )
 
@@ -21,20 +19,22 @@ type MetricIndexStore struct {
 	// tagedStopCh  chan struct{}
 	stopWG    sync.WaitGroup
 	isRunning *abool.AtomicBool
+
+	guard   *limits.Guard // tagged metrics limits (optional)
+	rejects io.Writer     // rejected metrics sink (optional)
 }
 
 func (bg *MetricIndexStore) spawnTagged() {
-	bg.stopWG.Add(1)
 	defer bg.stopWG.Done()
 
 	for m := range bg.taggedCh {
 		if bg.isRunning.IsNotSet() {
 			break
 		}
-		if d, n, err := bg.taggedDriver.Write(m); err != nil {
-			log.Printf("ERROR (%v): %v", d, err)
-		} else if n > 0 {
-			log.Printf("FLUSH (%v): %d", d, n)
+		if r, err := bg.taggedDriver.Write(m); err != nil {
+			log.Printf("ERROR (%v): %v, %s", r.Duration, err, r.String())
+		} else if !r.IsEmpty() {
+			log.Printf("FLUSH (%v): %s", r.Duration, r.String())
 		}
 	}
 
@@ -47,17 +47,35 @@ func (bg *MetricIndexStore) spawnTagged() {
 	// 	}
 	// }
 
-	if d, n, err := bg.taggedDriver.Flush(); err != nil {
-		log.Printf("ERROR (%v): %v", d, err)
-	} else if n > 0 {
-		log.Printf("FLUSH (%v): %d", d, n)
+	if r, err := bg.taggedDriver.Flush(); err != nil {
+		log.Printf("ERROR (%v): %v, %s", r.Duration, err, r.String())
+	} else if !r.IsEmpty() {
+		log.Printf("FLUSH (%v): %s", r.Duration, r.String())
+	}
+
+	if err := bg.taggedDriver.Close(); err != nil {
+		log.Printf("ERROR: close driver: %v", err)
 	}
 }
 
+// SetLimits set tagged metrics limits guard and sink for rejected metrics (metric and reason, tab-separated)
+func (bg *MetricIndexStore) SetLimits(guard *limits.Guard, rejects io.Writer) {
+	bg.guard = guard
+	bg.rejects = rejects
+}
+
 func (bg *MetricIndexStore) Push(m driver.MetricIndex) {
 	if strings.Contains(m.Metric, ";") {
 		// tagged metric
 		if bg.taggedDriver != nil {
+			if bg.guard != nil {
+				if err := bg.guard.Check(m.Metric, m.Date); err != nil {
+					if bg.rejects != nil {
+						fmt.Fprintf(bg.rejects, "%s\t%v\n", m.Metric, err)
+					}
+					return
+				}
+			}
 			bg.taggedCh <- m
 		}
 	}
@@ -83,38 +101,23 @@ func (bg *MetricIndexStore) FlushInit() {
 	bg.Push(driver.MetricIndex{})
 }
 
-func NewMetricIndexStore(chDriver ChDriver, address, plainTable, taggedTable string, flushSize uint, isRunning *abool.AtomicBool) (*MetricIndexStore, error) {
+func NewMetricIndexStore(chDriver ChDriver, dsn *driver.DSN, plainTable, taggedTable string, flushSize uint, tagsMode tags.Mode, tag1 *driver.Tag1Policy, isRunning *abool.AtomicBool) (*MetricIndexStore, error) {
 	var (
 		taggedDriver driver.Driver
 		err          error
 	)
 
-	switch chDriver {
-	case ChDriverMailRu:
-		if len(taggedTable) > 0 {
-			taggedDriver, err = driver_mail_ru.NewTaggedDriver(address, taggedTable, flushSize)
-		}
-	case ChDriverStd:
-		if len(taggedTable) > 0 {
-			taggedDriver, err = driver_std.NewTaggedDriver(address, taggedTable, flushSize)
+	if len(taggedTable) > 0 {
+		taggedDriver, err = driver.New(chDriver.String(), driver.CapTagged, driver.Config{
+			DSN:       dsn,
+			Table:     taggedTable,
+			FlushSize: flushSize,
+			TagsMode:  tagsMode,
+			Tag1:      tag1,
+		})
+		if err != nil {
+			return nil, err
 		}
-	case ChDriverNative:
-		if len(taggedTable) > 0 {
-			taggedDriver, err = driver_native.NewTaggedDriver(address, taggedTable, flushSize)
-		}
-	case ChDriverRowBinary:
-		if len(taggedTable) > 0 {
-			taggedDriver, err = driver_rowbin.NewTaggedDriver(address, taggedTable, flushSize)
-		}
-	case ChDriverCol:
-		if len(taggedTable) > 0 {
-			taggedDriver, err = driver_col.NewTaggedDriver(address, taggedTable, flushSize)
-		}
-	default:
-		return nil, fmt.Errorf("driver not supported: %s", chDriver.String())
-	}
-	if err != nil {
-		return nil, err
 	}
 
 	drv := &MetricIndexStore{
@@ -124,7 +127,10 @@ func NewMetricIndexStore(chDriver ChDriver, address, plainTable, taggedTable str
 		isRunning: isRunning,
 	}
 
-	go drv.spawnTagged()
+	if taggedDriver != nil {
+		drv.stopWG.Add(1)
+		go drv.spawnTagged()
+	}
 
 	return drv, nil
 }
@@ -0,0 +1,12 @@
+package main
+
+// drivers, registered in pkg/driver registry
+import (
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/columnar"
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/file"
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/mail_ru"
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/native"
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/nativehttp"
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/rowbin"
+	_ "github.com/msaf1980/carbon-clickhouse-loader/pkg/driver/std"
+)
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/compress"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	flag "github.com/spf13/pflag"
+)
+
+// tailReader keep last read bytes (for show decode error context)
+type tailReader struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // stream offset of buf[0]
+	size int
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.buf = append(t.buf, p[:n]...)
+		if len(t.buf) > 2*t.size {
+			drop := len(t.buf) - t.size
+			t.base += int64(drop)
+			t.buf = append(t.buf[:0], t.buf[drop:]...)
+		}
+	}
+	return n, err
+}
+
+// bytes return kept bytes in [start, end) range and actual range start
+func (t *tailReader) bytes(start, end int64) ([]byte, int64) {
+	if start < t.base {
+		start = t.base
+	}
+	if last := t.base + int64(len(t.buf)); end > last {
+		end = last
+	}
+	if start >= end {
+		return nil, start
+	}
+	return t.buf[start-t.base : end-t.base], start
+}
+
+// hexDump format bytes (from stream offset) with highlighted byte at mark offset
+func hexDump(data []byte, offset, mark int64) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i += 16 {
+		line := data[i:]
+		if len(line) > 16 {
+			line = line[:16]
+		}
+		lineOffset := offset + int64(i)
+		sb.WriteString(fmt.Sprintf("%08x ", lineOffset))
+		for j := 0; j < 16; j++ {
+			pos := lineOffset + int64(j)
+			switch {
+			case pos == mark:
+				sb.WriteByte('[')
+			case pos == mark+1 && j > 0:
+				sb.WriteByte(']')
+			default:
+				sb.WriteByte(' ')
+			}
+			if j < len(line) {
+				sb.WriteString(hex.EncodeToString(line[j : j+1]))
+			} else {
+				sb.WriteString("  ")
+			}
+		}
+		if lineOffset+15 == mark {
+			sb.WriteByte(']')
+		} else {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(" |")
+		for _, c := range line {
+			if c >= 0x20 && c < 0x7f {
+				sb.WriteByte(c)
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteString("|\n")
+	}
+	return sb.String()
+}
+
+// baseType strip Nullable, LowCardinality and Array wrappers
+func baseType(chType string) string {
+	for {
+		chType = strings.TrimSpace(chType)
+		found := false
+		for _, prefix := range []string{"Nullable(", "LowCardinality(", "Array("} {
+			if strings.HasPrefix(chType, prefix) && strings.HasSuffix(chType, ")") {
+				chType = chType[len(prefix) : len(chType)-1]
+				found = true
+			}
+		}
+		if !found {
+			return chType
+		}
+	}
+}
+
+func formatTime(t time.Time, isDate bool) string {
+	if isDate {
+		return t.Format("2006-01-02")
+	}
+	if t.Nanosecond() == 0 {
+		return t.Format("2006-01-02 15:04:05")
+	}
+	return t.Format("2006-01-02 15:04:05.999999999")
+}
+
+func formatUUID(u [16]byte) string {
+	s := hex.EncodeToString(u[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r", "\x00", "\\0")
+
+var quotedEscaper = strings.NewReplacer("\\", "\\\\", "'", "\\'", "\t", "\\t", "\n", "\\n", "\r", "\\r", "\x00", "\\0")
+
+// formatTSV format value like ClickHouse TabSeparated format
+func formatTSV(v interface{}, isDate, nested bool) string {
+	switch v := v.(type) {
+	case nil:
+		if nested {
+			return "NULL"
+		}
+		return "\\N"
+	case string:
+		if nested {
+			return "'" + quotedEscaper.Replace(v) + "'"
+		}
+		return tsvEscaper.Replace(v)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan"
+		case math.IsInf(v, 1):
+			return "inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		if nested {
+			return "'" + formatTime(v, isDate) + "'"
+		}
+		return formatTime(v, isDate)
+	case [16]byte:
+		if nested {
+			return "'" + formatUUID(v) + "'"
+		}
+		return formatUUID(v)
+	case *big.Int:
+		return v.String()
+	case []interface{}:
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = formatTSV(v[i], isDate, true)
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	case []RowBinary.MapEntry:
+		values := make([]string, len(v))
+		for i := range v {
+			values[i] = formatTSV(v[i].Key, false, true) + ":" + formatTSV(v[i].Value, false, true)
+		}
+		return "{" + strings.Join(values, ",") + "}"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// jsonValue convert value to JSON-encodable
+func jsonValue(v interface{}, isDate bool) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return formatTSV(v, false, false)
+		}
+		return v
+	case time.Time:
+		return formatTime(v, isDate)
+	case [16]byte:
+		return formatUUID(v)
+	case *big.Int:
+		return v.String()
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = jsonValue(v[i], isDate)
+		}
+		return values
+	case []RowBinary.MapEntry:
+		m := make(map[string]interface{}, len(v))
+		for _, e := range v {
+			var key string
+			if s, ok := e.Key.(string); ok {
+				key = s
+			} else {
+				key = formatTSV(e.Key, false, false)
+			}
+			m[key] = jsonValue(e.Value, false)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+type dumpWriter struct {
+	w       *bufio.Writer
+	json    bool
+	names   []string
+	isDate  []bool
+	started bool
+}
+
+func (d *dumpWriter) writeRow(row []interface{}) error {
+	if d.json {
+		d.w.WriteByte('{')
+		for i, v := range row {
+			if i > 0 {
+				d.w.WriteByte(',')
+			}
+			name, _ := json.Marshal(d.names[i])
+			d.w.Write(name)
+			d.w.WriteByte(':')
+			value, err := json.Marshal(jsonValue(v, d.isDate[i]))
+			if err != nil {
+				return err
+			}
+			d.w.Write(value)
+		}
+		d.w.WriteString("}\n")
+		return nil
+	}
+	if !d.started {
+		d.started = true
+		d.w.WriteString(strings.Join(d.names, "\t"))
+		d.w.WriteByte('\n')
+	}
+	for i, v := range row {
+		if i > 0 {
+			d.w.WriteByte('\t')
+		}
+		d.w.WriteString(formatTSV(v, d.isDate[i], false))
+	}
+	return d.w.WriteByte('\n')
+}
+
+// compressByExt detect compression method by file extension
+func compressByExt(filename string) string {
+	switch {
+	case strings.HasSuffix(filename, ".gz"):
+		return compress.Gzip
+	case strings.HasSuffix(filename, ".zst"), strings.HasSuffix(filename, ".zstd"):
+		return compress.ZSTD
+	case strings.HasSuffix(filename, ".lz4"):
+		return compress.LZ4
+	default:
+		return compress.None
+	}
+}
+
+func dumpUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: %s dump [flags] FILE\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Print RowBinary file (or captured request body, - for stdin) rows as TSV or JSON.\n")
+	fmt.Fprintf(os.Stderr, "Rows are printed as written, so Tag1 rows are already reduced by --tag1-keys of load.\n\n")
+	fs.PrintDefaults()
+}
+
+// dumpMain is a dump subcommand, return exit code
+func dumpMain(args []string) int {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	fs.Usage = func() { dumpUsage(fs) }
+	types := fs.StringP("types", "T", "", "columns types, like 'Date, String, Array(String), UInt32' (by default graphite tagged table layout or from header)")
+	names := fs.StringP("names", "N", "", "columns names (comma-separated)")
+	withHeader := fs.Bool("header", false, "input is RowBinaryWithNamesAndTypes (types are validated, if set)")
+	format := fs.StringP("format", "F", "tsv", "output format (tsv, json)")
+	compressMethod := fs.StringP("compress", "c", "", "input compression (none, gzip, zstd, lz4), by default detected by file extension")
+	limit := fs.IntP("limit", "n", 0, "max rows (0 for unlimited)")
+	contextSize := fs.Int("context", 32, "bytes before and after failed column offset to show")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		dumpUsage(fs)
+		return 2
+	}
+	if *format != "tsv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "invalid format: %s\n", *format)
+		return 2
+	}
+	filename := fs.Arg(0)
+
+	var colTypes, colNames []string
+	var err error
+	if *types != "" {
+		if colTypes, err = RowBinary.SplitTypes(*types); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid types: %v\n", err)
+			return 2
+		}
+	}
+	if *names != "" {
+		colNames = strings.Split(*names, ",")
+		for i := range colNames {
+			colNames[i] = strings.TrimSpace(colNames[i])
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+		if *compressMethod == "" {
+			*compressMethod = compressByExt(filename)
+		}
+	}
+	zr, err := compress.NewReader(in, *compressMethod)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer zr.Close()
+
+	tail := &tailReader{r: zr, size: 64*1024 + 2**contextSize}
+	r := RowBinary.NewReader(tail)
+
+	if *withHeader {
+		hNames, hTypes, err := r.ReadHeader()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: read header at offset %d: %v\n", r.Offset(), err)
+			return 1
+		}
+		wantNames := colNames
+		if wantNames == nil {
+			wantNames = hNames
+		}
+		wantTypes := colTypes
+		if wantTypes == nil {
+			wantTypes = hTypes
+		}
+		if err = RowBinary.ValidateHeader(hNames, hTypes, wantNames, wantTypes); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		colNames, colTypes = hNames, hTypes
+	}
+	if colTypes == nil {
+		colTypes = driver.TaggedCodec.Types()
+		if colNames == nil {
+			colNames = driver.TaggedCodec.Names()
+		}
+	}
+	if colNames == nil {
+		colNames = make([]string, len(colTypes))
+		for i := range colNames {
+			colNames[i] = "c" + strconv.Itoa(i+1)
+		}
+	}
+	if len(colNames) != len(colTypes) {
+		fmt.Fprintf(os.Stderr, "columns names count %d not equal to types count %d\n", len(colNames), len(colTypes))
+		return 2
+	}
+
+	decoder, err := RowBinary.NewRowDecoder(colTypes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	out := &dumpWriter{
+		w:      bufio.NewWriterSize(os.Stdout, 64*1024),
+		json:   *format == "json",
+		names:  colNames,
+		isDate: make([]bool, len(colTypes)),
+	}
+	defer out.w.Flush()
+	for i, t := range colTypes {
+		base := baseType(t)
+		out.isDate[i] = base == "Date" || base == "Date32"
+	}
+
+	var row []interface{}
+	for n := 0; *limit == 0 || n < *limit; n++ {
+		row, err = decoder.ReadRow(r, row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.w.Flush()
+			var decodeErr *RowBinary.DecodeError
+			if errors.As(err, &decodeErr) {
+				fmt.Fprintf(os.Stderr, "ERROR: row %d (offset %d), column %d %s %s (offset %d): %v\n",
+					decodeErr.Row, decodeErr.RowOffset, decodeErr.Column, colNames[decodeErr.Column],
+					colTypes[decodeErr.Column], decodeErr.Offset, decodeErr.Err,
+				)
+				for i, v := range row {
+					fmt.Fprintf(os.Stderr, "  %s: %s\n", colNames[i], formatTSV(v, out.isDate[i], false))
+				}
+				// read some bytes after failed offset
+				_, _ = io.CopyN(io.Discard, tail, int64(*contextSize))
+				data, start := tail.bytes(decodeErr.Offset-int64(*contextSize), decodeErr.Offset+int64(*contextSize))
+				fmt.Fprint(os.Stderr, hexDump(data, start, decodeErr.Offset))
+			} else {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			}
+			return 1
+		}
+		if err = out.writeRow(row); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+
+	return 0
+}
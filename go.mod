@@ -1,15 +1,29 @@
 module github.com/msaf1980/carbon-clickhouse-loader
 
-go 1.16
+go 1.18
 
 require (
 	github.com/ClickHouse/clickhouse-go/v2 v2.0.15
+	github.com/klauspost/compress v1.15.9
 	github.com/mailru/go-clickhouse/v2 v2.0.0
 	github.com/maruel/natural v1.1.0
 	github.com/msaf1980/go-stringutils v0.0.15
+	github.com/pierrec/lz4/v4 v4.1.14
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.7.2
 	github.com/tevino/abool v1.2.0
 	github.com/tevino/abool/v2 v2.1.0
 	github.com/vahid-sohrabloo/chconn v1.3.12
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/jackc/puddle v1.2.1 // indirect
+	github.com/paulmach/orb v0.7.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/shopspring/decimal v1.3.1 // indirect
+	go.opentelemetry.io/otel v1.7.0 // indirect
+	go.opentelemetry.io/otel/trace v1.7.0 // indirect
 )
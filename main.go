@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"log"
 	"os"
@@ -10,16 +11,27 @@ import (
 	"time"
 
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
 	flag "github.com/spf13/pflag"
 	"github.com/tevino/abool/v2"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "dump" {
+		os.Exit(dumpMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "query" {
+		os.Exit(queryMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "analyze" {
+		os.Exit(analyzeMain(os.Args[2:]))
+	}
+
 	var fileNames StringSlice
 	flag.VarP(&fileNames, "file", "f", "metrics file")
 
 	// var chURL *string = flag.StringP("url", "u", "", "clickhouse URL")
-	var chDriver ChDriver
+	chDriver := ChDriver("rowbin")
 	flag.VarP(&chDriver, "driver", "d", fmt.Sprintf("clickhouse driver %s", chDriver.Drivers()))
 
 	var chunkSize driver.Size = 1024 * 1024
@@ -31,21 +43,80 @@ func main() {
 	// indexTable := flag.StringP("index", "i", "", "graphite index table")
 	taggedTable := flag.StringP("tagged", "t", "", "graphite tagged table")
 
-	address := flag.StringP("address", "a", "", "clickhouse address")
+	address := flag.StringP("address", "a", "", "clickhouse address ([scheme://][user[:password]@]host[:port][,host2[:port2]][/database][?param=value&...])")
 
+	var tagsMode tags.Mode
+	flag.Var(&tagsMode, "tags-mode", "tagged path mode: natural (natural sort) or graphite (carbon-clickhouse compatible sort and escaping)")
+	var tag1Keys StringSlice
+	flag.Var(&tag1Keys, "tag1-keys", "emit Tag1 rows only for __name__ and these tag keys (default all tags), use __name__ for name rows only")
+	var pipelineFlags PipelineFlags
+	pipelineFlags.Register(flag.CommandLine)
+	rewriteDryRun := flag.Bool("rewrite-dry-run", false, "print rewritten metrics (before and after) and exit without loading")
+	rejectFile := flag.String("reject", "", "file for metrics, rejected by limits (metric and reason, tab-separated)")
+
+	credentialsFile := flag.String("credentials", "", "clickhouse credentials file with user=... and password=... lines (must be 0600)")
+	netrcFile := flag.String("netrc", "", "netrc file for lookup clickhouse credentials by host (by default $NETRC or ~/.netrc)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n       %s dump [flags] FILE (print RowBinary file, see dump --help)\n       %s query [flags] QUERY... (evaluate seriesByTag queries on metrics files, see query --help)\n       %s analyze [flags] (metrics files analysis report, see analyze --help)\n\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	var ec int
+	var (
+		ec            int
+		read, skipped uint64
+	)
 	isRunning := abool.NewBool(true)
 
-	store, err := NewMetricIndexStore(chDriver, *address, "", *taggedTable, uint(chunkSize), isRunning)
+	dsn, err := driver.ParseDSN(*address)
+	if err != nil {
+		log.Fatalf("invalid clickhouse address: %v", err)
+	}
+	if dsn.Password != "" {
+		log.Printf("WARN: password in clickhouse address is visible in process list, use %s, --credentials or --netrc", driver.EnvPassword)
+	}
+	if err = dsn.LoadCredentials(driver.Credentials{File: *credentialsFile, Netrc: *netrcFile}); err != nil {
+		log.Fatalf("error loading clickhouse credentials: %v", err)
+	}
+
+	pipeline, err := pipelineFlags.Pipeline()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *rewriteDryRun && pipeline.Rewrite == nil {
+		log.Fatal("rewrite rules file not set")
+	}
+	if *rewriteDryRun {
+		// no load
+		*taggedTable = ""
+	}
+
+	store, err := NewMetricIndexStore(chDriver, dsn, "", *taggedTable, uint(chunkSize), tagsMode, driver.NewTag1Policy(tag1Keys), isRunning)
 	if err != nil {
 		log.Fatalf("error creating store: %v", err)
 	}
 
+	var (
+		rejectsOut *os.File
+		rejects    *bufio.Writer
+	)
+	guard := pipelineFlags.Guard()
+	if guard != nil {
+		if *rejectFile != "" {
+			if rejectsOut, err = os.Create(*rejectFile); err != nil {
+				log.Fatalf("error creating reject file: %v", err)
+			}
+			rejects = bufio.NewWriter(rejectsOut)
+			store.SetLimits(guard, rejects)
+		} else {
+			store.SetLimits(guard, nil)
+		}
+	}
+
 	dates := []time.Time{time.Now()}
 
-	termCh := make(chan os.Signal)
+	termCh := make(chan os.Signal, 1)
 	signal.Notify(termCh, syscall.SIGTERM, syscall.SIGINT)
 
 	go func(isRunning *abool.AtomicBool) {
@@ -75,7 +146,26 @@ MAIN_LOOP:
 				if err != nil {
 					break MAIN_LOOP
 				}
-				metric := strings.TrimRight(line, "\n")
+				line = strings.TrimRight(line, "\n")
+				if len(line) == 0 {
+					continue
+				}
+				read++
+				metric, ok, err := pipeline.Process(line)
+				if err != nil {
+					log.Printf("skip %s:%d: %v", filename, n, err)
+					skipped++
+					continue
+				}
+				if !ok {
+					continue
+				}
+				if *rewriteDryRun {
+					if metric != line {
+						fmt.Printf("%s\t%s\n", line, metric)
+					}
+					continue
+				}
 				if len(metric) > 0 {
 					store.Push(driver.MetricIndex{
 						Metric: string(metric),
@@ -90,5 +180,28 @@ MAIN_LOOP:
 
 	store.Stop()
 
+	if pipeline.Filter != nil {
+		stats := pipeline.Filter.Stats()
+		log.Printf("metrics: read %d, invalid %d, filtered %d (excluded %d, not included %d)",
+			read, skipped, stats.Filtered(), stats.Excluded, stats.NotIncluded)
+	} else {
+		log.Printf("metrics: read %d, invalid %d", read, skipped)
+	}
+	if guard != nil {
+		var sb strings.Builder
+		guard.WriteSummary(&sb)
+		log.Print(sb.String())
+	}
+	if rejects != nil {
+		err = rejects.Flush()
+		if cerr := rejectsOut.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			log.Printf("error writing reject file: %v", err)
+			ec = 1
+		}
+	}
+
 	os.Exit(ec)
 }
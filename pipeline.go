@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/filter"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/limits"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/rewrite"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+	flag "github.com/spf13/pflag"
+)
+
+// PipelineFlags is a metrics pipeline and limits flags (shared by load and analyze)
+type PipelineFlags struct {
+	Prometheus  bool
+	Normalize   bool
+	Strict      bool
+	RewriteFile string
+	Include     StringSlice
+	Exclude     StringSlice
+	Limits      limits.Config
+}
+
+// Register register flags in flag set
+func (f *PipelineFlags) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.Prometheus, "prometheus", false, "input metrics are prometheus series (name{label=\"value\",...}), converted to graphite tagged metrics")
+	fs.BoolVar(&f.Normalize, "tags-normalize", false, "normalize tagged metrics (trim spaces, drop empty tags, last duplicate tag key wins)")
+	fs.BoolVar(&f.Strict, "tags-strict", false, "skip tagged metrics with invalid tags (by graphite tags spec)")
+
+	fs.StringVar(&f.RewriteFile, "rewrite", "", "tags rewrite rules file (YAML)")
+
+	fs.Var(&f.Include, "include", "load only metrics, matched by graphite glob (cpu.*.load{1,5}, tagged metrics are matched by name) or seriesByTag expressions (seriesByTag('name=cpu','env!=test'))")
+	fs.Var(&f.Exclude, "exclude", "skip metrics, matched by graphite glob or seriesByTag expressions")
+
+	fs.IntVar(&f.Limits.MaxTags, "max-tags", 0, "max tags per tagged metric (0 for unlimited)")
+	fs.IntVar(&f.Limits.MaxKeyLength, "max-key-length", 0, "max tag key length (0 for unlimited)")
+	fs.IntVar(&f.Limits.MaxValueLength, "max-value-length", 0, "max tag value length (0 for unlimited)")
+	fs.IntVar(&f.Limits.MaxValues, "max-tag-values", 0, "max distinct values per tag key per date (0 for unlimited)")
+	fs.IntVar(&f.Limits.MaxMetricLen, "max-metric-length", 0, "max tagged metric length (0 for unlimited)")
+}
+
+// Pipeline create pipeline (load rewrite rules and compile filter)
+func (f *PipelineFlags) Pipeline() (Pipeline, error) {
+	var err error
+	pipeline := Pipeline{Prometheus: f.Prometheus, Normalize: f.Normalize, Strict: f.Strict}
+	if f.RewriteFile != "" {
+		if pipeline.Rewrite, err = rewrite.Load(f.RewriteFile); err != nil {
+			return pipeline, fmt.Errorf("error loading rewrite rules: %w", err)
+		}
+	}
+	if len(f.Include) > 0 || len(f.Exclude) > 0 {
+		if pipeline.Filter, err = filter.New(f.Include, f.Exclude); err != nil {
+			return pipeline, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	return pipeline, nil
+}
+
+// Guard return limits guard (nil if limits not set)
+func (f *PipelineFlags) Guard() *limits.Guard {
+	if !f.Limits.Enabled() {
+		return nil
+	}
+	return limits.New(f.Limits)
+}
+
+// Pipeline transform and check metrics before push
+type Pipeline struct {
+	Prometheus bool           // input metrics are prometheus series (name{label="value",...})
+	Normalize  bool           // normalize tagged metrics
+	Strict     bool           // validate tagged metrics
+	Rewrite    *rewrite.Rules // rewrite rules (optional)
+	Filter     *filter.Filter // include/exclude filter (optional)
+}
+
+// Process return transformed metric and false if metric is filtered or error, if metric is invalid
+func (p *Pipeline) Process(metric string) (string, bool, error) {
+	var err error
+	if p.Prometheus {
+		if metric, err = tags.FromPrometheus(metric); err != nil {
+			return metric, false, err
+		}
+	}
+	if p.Normalize && strings.Contains(metric, ";") {
+		if metric, err = tags.Normalize(metric); err != nil {
+			return metric, false, err
+		}
+	}
+	if p.Rewrite != nil {
+		if metric, err = p.Rewrite.Apply(metric); err != nil {
+			return metric, false, err
+		}
+	}
+	if p.Filter != nil {
+		if ok, err := p.Filter.Match(metric); !ok || err != nil {
+			return metric, false, err
+		}
+	}
+	if p.Strict && strings.Contains(metric, ";") {
+		if err = tags.Validate(metric); err != nil {
+			return metric, false, err
+		}
+	}
+	return metric, true, nil
+}
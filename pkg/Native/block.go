@@ -0,0 +1,80 @@
+package Native
+
+import (
+	"fmt"
+	"io"
+)
+
+// Block is a ClickHouse Native format block (as used in HTTP interface, without block info)
+type Block struct {
+	columns []Column
+	buf     []byte
+}
+
+func NewBlock(columns ...Column) *Block {
+	return &Block{columns: columns}
+}
+
+func (b *Block) Columns() []Column {
+	return b.columns
+}
+
+// Rows return block rows count or error, if columns has different rows count
+func (b *Block) Rows() (int, error) {
+	if len(b.columns) == 0 {
+		return 0, nil
+	}
+	rows := b.columns[0].Rows()
+	for _, c := range b.columns[1:] {
+		if c.Rows() != rows {
+			return 0, fmt.Errorf("mismatched rows in column %s: %d, want %d", c.Name(), c.Rows(), rows)
+		}
+	}
+	return rows, nil
+}
+
+// AppendTo append encoded block
+func (b *Block) AppendTo(buf []byte) ([]byte, error) {
+	rows, err := b.Rows()
+	if err != nil {
+		return buf, err
+	}
+	buf = appendUvarint(buf, uint64(len(b.columns)))
+	buf = appendUvarint(buf, uint64(rows))
+	for _, c := range b.columns {
+		buf = appendString(buf, c.Name())
+		buf = appendString(buf, c.Type())
+		buf = c.AppendPrefix(buf)
+		buf = c.AppendData(buf)
+	}
+	return buf, nil
+}
+
+// WriteTo write encoded block to w
+func (b *Block) WriteTo(w io.Writer) (int64, error) {
+	var err error
+	if b.buf, err = b.AppendTo(b.buf[:0]); err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b.buf)
+	return int64(n), err
+}
+
+// Reset clear columns for reuse
+func (b *Block) Reset() {
+	for _, c := range b.columns {
+		c.Reset()
+	}
+}
+
+// ColumnsNames return columns names, comma-separated (for INSERT query)
+func (b *Block) ColumnsNames() string {
+	var names []byte
+	for i, c := range b.columns {
+		if i > 0 {
+			names = append(names, ", "...)
+		}
+		names = append(names, c.Name()...)
+	}
+	return string(names)
+}
@@ -0,0 +1,129 @@
+package Native
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	chbinary "github.com/ClickHouse/clickhouse-go/v2/lib/binary"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taggedRow struct {
+	date    time.Time
+	tag1    string
+	path    string
+	tags    []string
+	version uint32
+}
+
+// encode block with clickhouse-go as reference
+func referenceBlock(t *testing.T, rows []taggedRow) []byte {
+	var block proto.Block
+	require.NoError(t, block.AddColumn("Date", "Date"))
+	require.NoError(t, block.AddColumn("Tag1", "LowCardinality(String)"))
+	require.NoError(t, block.AddColumn("Path", "String"))
+	require.NoError(t, block.AddColumn("Tags", "Array(String)"))
+	require.NoError(t, block.AddColumn("Version", "UInt32"))
+	for _, r := range rows {
+		require.NoError(t, block.Append(r.date, r.tag1, r.path, r.tags, r.version))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, block.Encode(chbinary.NewEncoder(&buf), 0))
+	return buf.Bytes()
+}
+
+func TestBlock(t *testing.T) {
+	date := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rows []taggedRow
+	}{
+		{name: "empty"},
+		{
+			name: "tagged",
+			rows: []taggedRow{
+				{date, "__name__=cpu", "cpu?env=test", []string{"__name__=cpu", "env=test"}, 1},
+				{date, "env=test", "cpu?env=test", []string{"__name__=cpu", "env=test"}, 1},
+				{date, "__name__=cpu", "cpu", []string{}, 2},
+				{date, "env=prod", "cpu?env=prod", []string{"env=prod"}, 3},
+			},
+		},
+		{
+			name: "uint16 keys",
+			rows: func() []taggedRow {
+				rows := make([]taggedRow, 1000)
+				for i := range rows {
+					rows[i] = taggedRow{date, "host=host" + strconv.Itoa(i), "path", []string{"a"}, uint32(i)}
+				}
+				return rows
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block := NewBlock(
+				NewDate("Date"), NewLowCardinalityString("Tag1"), NewString("Path"),
+				NewArrayString("Tags"), NewUInt32("Version"),
+			)
+			// check reuse
+			for i := 0; i < 2; i++ {
+				block.Reset()
+				for _, r := range tt.rows {
+					block.columns[0].(*Date).Append(r.date)
+					block.columns[1].(*LowCardinalityString).Append(r.tag1)
+					block.columns[2].(*String).Append(r.path)
+					block.columns[3].(*ArrayString).Append(r.tags)
+					block.columns[4].(*UInt32).Append(r.version)
+				}
+				got, err := block.AppendTo(nil)
+				require.NoError(t, err)
+				assert.Equal(t, referenceBlock(t, tt.rows), got)
+			}
+		})
+	}
+}
+
+func TestBlockMismatchedRows(t *testing.T) {
+	path := NewString("Path")
+	version := NewUInt32("Version")
+	block := NewBlock(path, version)
+	path.Append("cpu")
+	_, err := block.AppendTo(nil)
+	assert.Error(t, err)
+
+	version.Append(1)
+	var buf bytes.Buffer
+	_, err = block.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "Path, Version", block.ColumnsNames())
+}
+
+func TestArrayStringEncoded(t *testing.T) {
+	a := NewArrayString("Tags")
+	b := NewArrayString("Tags")
+	tags := []string{"__name__=cpu", "env=test"}
+	a.Append(tags)
+	a.Append(nil)
+	b.AppendEncoded(len(tags), AppendStrings(nil, tags))
+	b.AppendEncoded(0, nil)
+	assert.Equal(t, a.AppendData(nil), b.AppendData(nil))
+}
+
+// reference encoder add duplicate empty key, so empty value is checked without it
+func TestLowCardinalityStringEmpty(t *testing.T) {
+	c := NewLowCardinalityString("Tag1")
+	// check reuse
+	for i := 0; i < 2; i++ {
+		c.Reset()
+		c.Append("")
+		c.Append("a")
+		c.Append("")
+		assert.Equal(t, []string{"", "a"}, c.index)
+		assert.Len(t, c.dict, 2)
+		assert.Equal(t, []uint32{0, 1, 0}, c.keys)
+	}
+}
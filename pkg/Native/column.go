@@ -0,0 +1,281 @@
+package Native
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+)
+
+// Column is a ClickHouse Native format column
+type Column interface {
+	Name() string
+	Type() string
+	Rows() int
+	// AppendPrefix append column serialization state prefix (written before column data)
+	AppendPrefix(buf []byte) []byte
+	// AppendData append column data
+	AppendData(buf []byte) []byte
+	Reset()
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendUint16(buf []byte, v uint16) []byte {
+	return append(buf, byte(v), byte(v>>8))
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+type column struct {
+	name string
+	rows int
+	data []byte // encoded data
+}
+
+func (c *column) Name() string {
+	return c.name
+}
+
+func (c *column) Rows() int {
+	return c.rows
+}
+
+func (c *column) AppendPrefix(buf []byte) []byte {
+	return buf
+}
+
+func (c *column) AppendData(buf []byte) []byte {
+	return append(buf, c.data...)
+}
+
+func (c *column) Reset() {
+	c.rows = 0
+	c.data = c.data[:0]
+}
+
+// String is a String column
+type String struct {
+	column
+}
+
+func NewString(name string) *String {
+	return &String{column{name: name}}
+}
+
+func (c *String) Type() string {
+	return "String"
+}
+
+func (c *String) Append(v string) {
+	c.data = appendString(c.data, v)
+	c.rows++
+}
+
+// Date is a Date column
+type Date struct {
+	column
+}
+
+func NewDate(name string) *Date {
+	return &Date{column{name: name}}
+}
+
+func (c *Date) Type() string {
+	return "Date"
+}
+
+func (c *Date) Append(v time.Time) {
+	c.AppendUint16(RowBinary.DateToUint16(v))
+}
+
+// AppendUint16 append date as days since epoch
+func (c *Date) AppendUint16(v uint16) {
+	c.data = appendUint16(c.data, v)
+	c.rows++
+}
+
+// UInt32 is a UInt32 column
+type UInt32 struct {
+	column
+}
+
+func NewUInt32(name string) *UInt32 {
+	return &UInt32{column{name: name}}
+}
+
+func (c *UInt32) Type() string {
+	return "UInt32"
+}
+
+func (c *UInt32) Append(v uint32) {
+	c.data = appendUint32(c.data, v)
+	c.rows++
+}
+
+// ArrayString is a Array(String) column: offsets (UInt64, cumulative) for all rows, after them nested values
+type ArrayString struct {
+	column
+	offset uint64
+	values []byte // encoded nested String values
+}
+
+func NewArrayString(name string) *ArrayString {
+	return &ArrayString{column: column{name: name}}
+}
+
+func (c *ArrayString) Type() string {
+	return "Array(String)"
+}
+
+func (c *ArrayString) Append(v []string) {
+	c.offset += uint64(len(v))
+	c.data = appendUint64(c.data, c.offset)
+	for _, s := range v {
+		c.values = appendString(c.values, s)
+	}
+	c.rows++
+}
+
+// AppendEncoded append array with already encoded values (with AppendStrings)
+func (c *ArrayString) AppendEncoded(n int, values []byte) {
+	c.offset += uint64(n)
+	c.data = appendUint64(c.data, c.offset)
+	c.values = append(c.values, values...)
+	c.rows++
+}
+
+func (c *ArrayString) AppendData(buf []byte) []byte {
+	buf = append(buf, c.data...)
+	return append(buf, c.values...)
+}
+
+func (c *ArrayString) Reset() {
+	c.column.Reset()
+	c.offset = 0
+	c.values = c.values[:0]
+}
+
+// AppendStrings encode strings for ArrayString.AppendEncoded
+func AppendStrings(buf []byte, v []string) []byte {
+	for _, s := range v {
+		buf = appendString(buf, s)
+	}
+	return buf
+}
+
+// LowCardinality serialization flags
+const (
+	sharedDictionariesWithAdditionalKeys = 1
+
+	keyUInt8  = 0
+	keyUInt16 = 1
+	keyUInt32 = 2
+	keyUInt64 = 3
+
+	hasAdditionalKeysBit = 1 << 9
+	needUpdateDictionary = 1 << 10
+)
+
+// LowCardinalityString is a LowCardinality(String) column: dictionary (with default value at 0 position) and keys
+type LowCardinalityString struct {
+	name  string
+	dict  map[string]uint32
+	index []string
+	keys  []uint32
+}
+
+func NewLowCardinalityString(name string) *LowCardinalityString {
+	return &LowCardinalityString{
+		name:  name,
+		dict:  map[string]uint32{"": 0},
+		index: []string{""},
+	}
+}
+
+func (c *LowCardinalityString) Name() string {
+	return c.name
+}
+
+func (c *LowCardinalityString) Type() string {
+	return "LowCardinality(String)"
+}
+
+func (c *LowCardinalityString) Rows() int {
+	return len(c.keys)
+}
+
+func (c *LowCardinalityString) Append(v string) {
+	key, ok := c.dict[v]
+	if !ok {
+		key = uint32(len(c.index))
+		c.index = append(c.index, v)
+		c.dict[v] = key
+	}
+	c.keys = append(c.keys, key)
+}
+
+func (c *LowCardinalityString) AppendPrefix(buf []byte) []byte {
+	return appendUint64(buf, sharedDictionariesWithAdditionalKeys)
+}
+
+func (c *LowCardinalityString) AppendData(buf []byte) []byte {
+	if len(c.keys) == 0 {
+		return buf
+	}
+	var keyType uint64
+	switch n := len(c.index); {
+	case n < math.MaxUint8:
+		keyType = keyUInt8
+	case n < math.MaxUint16:
+		keyType = keyUInt16
+	default:
+		keyType = keyUInt32
+	}
+	buf = appendUint64(buf, hasAdditionalKeysBit|needUpdateDictionary|keyType)
+	buf = appendUint64(buf, uint64(len(c.index)))
+	for _, s := range c.index {
+		buf = appendString(buf, s)
+	}
+	buf = appendUint64(buf, uint64(len(c.keys)))
+	for _, key := range c.keys {
+		switch keyType {
+		case keyUInt8:
+			buf = append(buf, byte(key))
+		case keyUInt16:
+			buf = appendUint16(buf, uint16(key))
+		default:
+			buf = appendUint32(buf, key)
+		}
+	}
+	return buf
+}
+
+func (c *LowCardinalityString) Reset() {
+	for k := range c.dict {
+		delete(c.dict, k)
+	}
+	c.dict[""] = 0
+	c.index = c.index[:1]
+	c.keys = c.keys[:0]
+}
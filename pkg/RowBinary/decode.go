@@ -0,0 +1,336 @@
+package RowBinary
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ValueReader read value of ClickHouse type. Values are
+//
+//	UInt*, Int*, Float*: uint64, int64, float64
+//	Date, DateTime, DateTime64: time.Time
+//	String, FixedString, LowCardinality(String): string
+//	UUID: [16]byte
+//	Decimal32, Decimal64: float64, Decimal128: *big.Int (scaled)
+//	Nullable(T): nil for NULL
+//	Array(T), Tuple(T1, ...): []interface{}
+//	Map(K, V): []MapEntry (in stream order)
+type ValueReader func(r *Reader) (interface{}, error)
+
+// MapEntry is a Map(K, V) element, decoded by ValueReader
+type MapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// SplitTypes split comma-separated types list (commas in type arguments are skipped),
+// like "Date, Map(String, String), UInt32"
+func SplitTypes(types string) ([]string, error) {
+	var (
+		list  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(types); i++ {
+		switch types[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in '%s'", types)
+			}
+		case '\'':
+			// skip quoted string (Enum values)
+			for i++; i < len(types) && types[i] != '\''; i++ {
+				if types[i] == '\\' {
+					i++
+				}
+			}
+		case ',':
+			if depth == 0 {
+				list = append(list, strings.TrimSpace(types[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in '%s'", types)
+	}
+	if last := strings.TrimSpace(types[start:]); last != "" || len(list) > 0 {
+		list = append(list, last)
+	}
+	for _, t := range list {
+		if t == "" {
+			return nil, fmt.Errorf("empty type in '%s'", types)
+		}
+	}
+	return list, nil
+}
+
+// NewValueReader create reader for ClickHouse type
+func NewValueReader(chType string) (ValueReader, error) {
+	chType = strings.TrimSpace(chType)
+	if arg, ok := typeArgs(chType, "LowCardinality"); ok {
+		return NewValueReader(arg)
+	}
+	if arg, ok := typeArgs(chType, "Nullable"); ok {
+		elem, err := NewValueReader(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *Reader) (interface{}, error) {
+			isNull, err := r.ReadIsNull()
+			if err != nil || isNull {
+				return nil, err
+			}
+			v, err := elem(r)
+			return v, noEOF(err)
+		}, nil
+	}
+	if arg, ok := typeArgs(chType, "Array"); ok {
+		elem, err := NewValueReader(arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(r *Reader) (interface{}, error) {
+			n, err := r.readLen()
+			if err != nil {
+				return nil, err
+			}
+			list := make([]interface{}, 0, capHint(n))
+			for i := 0; i < n; i++ {
+				v, err := elem(r)
+				if err != nil {
+					return list, noEOF(err)
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		}, nil
+	}
+	if arg, ok := typeArgs(chType, "Tuple"); ok {
+		types, err := SplitTypes(arg)
+		if err != nil {
+			return nil, err
+		}
+		elems := make([]ValueReader, len(types))
+		for i, t := range types {
+			// named tuple element, like Tuple(a String)
+			if n := strings.IndexByte(t, ' '); n > 0 && !strings.ContainsRune(t[:n], '(') {
+				t = strings.TrimSpace(t[n+1:])
+			}
+			if elems[i], err = NewValueReader(t); err != nil {
+				return nil, err
+			}
+		}
+		return func(r *Reader) (interface{}, error) {
+			values := make([]interface{}, 0, len(elems))
+			for i, elem := range elems {
+				v, err := elem(r)
+				if err != nil {
+					if i > 0 {
+						err = noEOF(err)
+					}
+					return values, err
+				}
+				values = append(values, v)
+			}
+			return values, nil
+		}, nil
+	}
+	if arg, ok := typeArgs(chType, "Map"); ok {
+		types, err := SplitTypes(arg)
+		if err != nil {
+			return nil, err
+		}
+		if len(types) != 2 {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+		}
+		key, err := NewValueReader(types[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := NewValueReader(types[1])
+		if err != nil {
+			return nil, err
+		}
+		return func(r *Reader) (interface{}, error) {
+			n, err := r.readLen()
+			if err != nil {
+				return nil, err
+			}
+			entries := make([]MapEntry, 0, capHint(n))
+			for i := 0; i < n; i++ {
+				var e MapEntry
+				if e.Key, err = key(r); err != nil {
+					return entries, noEOF(err)
+				}
+				if e.Value, err = value(r); err != nil {
+					return entries, noEOF(err)
+				}
+				entries = append(entries, e)
+			}
+			return entries, nil
+		}, nil
+	}
+	if arg, ok := typeArgs(chType, "FixedString"); ok {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+		}
+		return func(r *Reader) (interface{}, error) { return r.ReadFixedString(n) }, nil
+	}
+	if arg, ok := typeArgs(chType, "DateTime64"); ok {
+		// DateTime64(precision[, timezone])
+		if n := strings.IndexByte(arg, ','); n >= 0 {
+			arg = strings.TrimSpace(arg[:n])
+		}
+		precision, err := strconv.Atoi(arg)
+		if err != nil || precision < 0 || precision > 9 {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+		}
+		return func(r *Reader) (interface{}, error) { return r.ReadDateTime64(precision) }, nil
+	}
+	if _, ok := typeArgs(chType, "DateTime"); ok {
+		// DateTime(timezone)
+		chType = "DateTime"
+	}
+	if arg, ok := typeArgs(chType, "Decimal"); ok {
+		// Decimal(P, S)
+		args, err := SplitTypes(arg)
+		if err != nil || len(args) != 2 {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+		}
+		p, err1 := strconv.Atoi(args[0])
+		s, err2 := strconv.Atoi(args[1])
+		if err1 != nil || err2 != nil || p < 1 || p > 38 || s < 0 || s > p {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+		}
+		switch {
+		case p <= 9:
+			chType = "Decimal32(" + args[1] + ")"
+		case p <= 18:
+			chType = "Decimal64(" + args[1] + ")"
+		default:
+			chType = "Decimal128(" + args[1] + ")"
+		}
+	}
+	for _, decimal := range []string{"Decimal32", "Decimal64", "Decimal128"} {
+		if arg, ok := typeArgs(chType, decimal); ok {
+			maxScale := 38
+			switch decimal {
+			case "Decimal32":
+				maxScale = Decimal32Precision
+			case "Decimal64":
+				maxScale = Decimal64Precision
+			}
+			scale, err := strconv.Atoi(arg)
+			if err != nil || scale < 0 || scale > maxScale {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+			}
+			switch decimal {
+			case "Decimal32":
+				return func(r *Reader) (interface{}, error) { return r.ReadDecimal32(scale) }, nil
+			case "Decimal64":
+				return func(r *Reader) (interface{}, error) { return r.ReadDecimal64(scale) }, nil
+			default:
+				return func(r *Reader) (interface{}, error) { return r.ReadDecimal128() }, nil
+			}
+		}
+	}
+	if _, ok := typeArgs(chType, "Enum8"); ok {
+		chType = "Int8"
+	} else if _, ok := typeArgs(chType, "Enum16"); ok {
+		chType = "Int16"
+	}
+
+	switch chType {
+	case "UInt8", "Bool":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadUint8(); return uint64(v), err }, nil
+	case "UInt16":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadUint16(); return uint64(v), err }, nil
+	case "UInt32":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadUint32(); return uint64(v), err }, nil
+	case "UInt64":
+		return func(r *Reader) (interface{}, error) { return r.ReadUint64() }, nil
+	case "Int8":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadInt8(); return int64(v), err }, nil
+	case "Int16":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadInt16(); return int64(v), err }, nil
+	case "Int32":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadInt32(); return int64(v), err }, nil
+	case "Int64":
+		return func(r *Reader) (interface{}, error) { return r.ReadInt64() }, nil
+	case "Float32":
+		return func(r *Reader) (interface{}, error) { v, err := r.ReadFloat32(); return float64(v), err }, nil
+	case "Float64":
+		return func(r *Reader) (interface{}, error) { return r.ReadFloat64() }, nil
+	case "String":
+		return func(r *Reader) (interface{}, error) { return r.ReadString() }, nil
+	case "Date":
+		return func(r *Reader) (interface{}, error) { return r.ReadDate() }, nil
+	case "DateTime":
+		return func(r *Reader) (interface{}, error) { return r.ReadDateTime() }, nil
+	case "UUID":
+		return func(r *Reader) (interface{}, error) { return r.ReadUUID() }, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, chType)
+}
+
+// DecodeError describe where row decoding failed
+type DecodeError struct {
+	Row       int   // row number (from 0)
+	Column    int   // column index
+	RowOffset int64 // row start offset
+	Offset    int64 // failed column start offset
+	Err       error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("row %d (offset %d), column %d (offset %d): %v", e.Row, e.RowOffset, e.Column, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// RowDecoder read rows with columns values
+type RowDecoder struct {
+	readers []ValueReader
+	rows    int
+}
+
+// NewRowDecoder create decoder for columns types
+func NewRowDecoder(types []string) (*RowDecoder, error) {
+	d := &RowDecoder{readers: make([]ValueReader, len(types))}
+	for i, t := range types {
+		var err error
+		if d.readers[i], err = NewValueReader(t); err != nil {
+			return nil, fmt.Errorf("column %d: %w", i, err)
+		}
+	}
+	return d, nil
+}
+
+// ReadRow read row values into row (reallocated if needed).
+// Return io.EOF if stream ended before row and *DecodeError on decode error.
+func (d *RowDecoder) ReadRow(r *Reader, row []interface{}) ([]interface{}, error) {
+	row = row[:0]
+	rowOffset := r.Offset()
+	for i, read := range d.readers {
+		offset := r.Offset()
+		v, err := read(r)
+		if err != nil {
+			if i == 0 && err == io.EOF {
+				return row, err
+			}
+			return row, &DecodeError{Row: d.rows, Column: i, RowOffset: rowOffset, Offset: offset, Err: noEOF(err)}
+		}
+		row = append(row, v)
+	}
+	d.rows++
+	return row, nil
+}
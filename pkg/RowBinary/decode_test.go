@@ -0,0 +1,179 @@
+package RowBinary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTypes(t *testing.T) {
+	tests := []struct {
+		types   string
+		want    []string
+		wantErr bool
+	}{
+		{types: "", want: nil},
+		{types: "UInt32", want: []string{"UInt32"}},
+		{types: "Date, String,Array(String) ,UInt32", want: []string{"Date", "String", "Array(String)", "UInt32"}},
+		{types: "Map(String, String), Tuple(a String, b Array(UInt8))", want: []string{"Map(String, String)", "Tuple(a String, b Array(UInt8))"}},
+		{types: "Enum8('a,)' = 1, 'b' = 2), Decimal(10, 2)", want: []string{"Enum8('a,)' = 1, 'b' = 2)", "Decimal(10, 2)"}},
+		{types: "Array(String", wantErr: true},
+		{types: "String)", wantErr: true},
+		{types: "String,,UInt32", wantErr: true},
+		{types: "String,", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.types, func(t *testing.T) {
+			got, err := SplitTypes(tt.types)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewValueReader(t *testing.T) {
+	date := time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)
+	ts := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	comment := "comment"
+	uuid := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+	tests := []struct {
+		chType string
+		write  func(w *Writer)
+		want   interface{}
+	}{
+		{chType: "UInt8", write: func(w *Writer) { w.WriteUint8(1) }, want: uint64(1)},
+		{chType: "Bool", write: func(w *Writer) { w.WriteUint8(1) }, want: uint64(1)},
+		{chType: "UInt16", write: func(w *Writer) { w.WriteUint16(2) }, want: uint64(2)},
+		{chType: "UInt32", write: func(w *Writer) { w.WriteUint32(3) }, want: uint64(3)},
+		{chType: "UInt64", write: func(w *Writer) { w.WriteUint64(4) }, want: uint64(4)},
+		{chType: "Int8", write: func(w *Writer) { w.WriteInt8(-1) }, want: int64(-1)},
+		{chType: "Enum8('a' = 1)", write: func(w *Writer) { w.WriteInt8(1) }, want: int64(1)},
+		{chType: "Int16", write: func(w *Writer) { w.WriteInt16(-2) }, want: int64(-2)},
+		{chType: "Enum16('a' = 1000)", write: func(w *Writer) { w.WriteInt16(1000) }, want: int64(1000)},
+		{chType: "Int32", write: func(w *Writer) { w.WriteInt32(-3) }, want: int64(-3)},
+		{chType: "Int64", write: func(w *Writer) { w.WriteInt64(-4) }, want: int64(-4)},
+		{chType: "Float32", write: func(w *Writer) { w.WriteFloat32(1.5) }, want: 1.5},
+		{chType: "Float64", write: func(w *Writer) { w.WriteFloat64(-2.5) }, want: -2.5},
+		{chType: "String", write: func(w *Writer) { w.WriteString("test") }, want: "test"},
+		{chType: "LowCardinality(String)", write: func(w *Writer) { w.WriteString("test") }, want: "test"},
+		{chType: "FixedString(4)", write: func(w *Writer) { w.WriteFixedString("ab", 4) }, want: "ab"},
+		{chType: "Date", write: func(w *Writer) { w.WriteDate(date) }, want: date},
+		{chType: "DateTime", write: func(w *Writer) { w.WriteDateTime(ts) }, want: ts},
+		{chType: "DateTime('UTC')", write: func(w *Writer) { w.WriteDateTime(ts) }, want: ts},
+		{chType: "DateTime64(3, 'UTC')", write: func(w *Writer) { w.WriteDateTime64(ts, 3) }, want: ts},
+		{chType: "UUID", write: func(w *Writer) { w.WriteUUID(uuid) }, want: uuid},
+		{chType: "Decimal32(2)", write: func(w *Writer) { w.WriteDecimal32(1.25, 2) }, want: 1.25},
+		{chType: "Decimal(10, 3)", write: func(w *Writer) { w.WriteDecimal64(-1.125, 3) }, want: -1.125},
+		{chType: "Decimal(20, 3)", write: func(w *Writer) { w.WriteDecimal128(big.NewInt(-1125)) }, want: big.NewInt(-1125)},
+		{chType: "Nullable(String)", write: func(w *Writer) { w.WriteNullableString("", true) }, want: nil},
+		{chType: "Nullable(String)", write: func(w *Writer) { w.WriteNullableString(comment, false) }, want: comment},
+		{
+			chType: "Array(Nullable(UInt32))",
+			write:  func(w *Writer) { w.NullableUint32List([]uint32{1, NullUint32}) },
+			want:   []interface{}{uint64(1), nil},
+		},
+		{
+			chType: "Array(Array(String))",
+			write: func(w *Writer) {
+				w.WriteUvarint(2)
+				w.WriteStringList([]string{"a"})
+				w.WriteStringList([]string{})
+			},
+			want: []interface{}{[]interface{}{"a"}, []interface{}{}},
+		},
+		{
+			chType: "Tuple(name String, value UInt32)",
+			write: func(w *Writer) {
+				w.WriteString("a")
+				w.WriteUint32(1)
+			},
+			want: []interface{}{"a", uint64(1)},
+		},
+		{
+			chType: "Map(String, UInt64)",
+			write: func(w *Writer) {
+				w.WriteUvarint(2)
+				w.WriteString("b")
+				w.WriteUint64(2)
+				w.WriteString("a")
+				w.WriteUint64(1)
+			},
+			want: []MapEntry{{Key: "b", Value: uint64(2)}, {Key: "a", Value: uint64(1)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.chType, func(t *testing.T) {
+			read, err := NewValueReader(tt.chType)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			tt.write(NewWriter(&buf))
+			r := NewReaderBuffered(&buf, 0)
+			got, err := read(r)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// want EOF
+			_, err = read(r)
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestNewValueReaderUnsupported(t *testing.T) {
+	for _, chType := range []string{"Int128", "Map(String)", "FixedString(a)", "DateTime64(10)", "Decimal(40, 2)", "Decimal32(10)", "Decimal64(19)", "Array(Unknown)"} {
+		t.Run(chType, func(t *testing.T) {
+			_, err := NewValueReader(chType)
+			assert.ErrorIs(t, err, ErrUnsupportedType)
+		})
+	}
+}
+
+func TestRowDecoder(t *testing.T) {
+	d, err := NewRowDecoder([]string{"Date", "String", "Array(String)", "UInt32"})
+	require.NoError(t, err)
+
+	date := time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)
+	e := NewEncoder(0)
+	e.WriteDate(date)
+	e.WriteString("a")
+	e.WriteStringList([]string{"b"})
+	e.WriteUint32(1)
+	rowSize := e.Len()
+	// broken row
+	e.WriteDate(date)
+	e.WriteString("c")
+	e.WriteUvarint(2)
+	e.WriteString("d")
+
+	r := NewReaderBuffered(bytes.NewReader(e.Bytes()), 0)
+	row, err := d.ReadRow(r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{date, "a", []interface{}{"b"}, uint64(1)}, row)
+
+	row, err = d.ReadRow(r, row)
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr), err)
+	assert.Equal(t, &DecodeError{Row: 1, Column: 2, RowOffset: int64(rowSize), Offset: int64(rowSize + 2 + 2), Err: ErrEOF}, decodeErr)
+	assert.ErrorIs(t, err, ErrEOF)
+	assert.Equal(t, []interface{}{date, "c"}, row)
+
+	d, err = NewRowDecoder([]string{"UInt8"})
+	require.NoError(t, err)
+	_, err = d.ReadRow(NewReaderBuffered(bytes.NewReader(nil), 0), nil)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = NewRowDecoder([]string{"UInt8", "Unknown"})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
@@ -0,0 +1,214 @@
+package RowBinary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/msaf1980/go-stringutils"
+)
+
+// maxPooledSize is a max buffer capacity, returned to pool (larger buffers are dropped)
+const maxPooledSize = 16 * 1024 * 1024
+
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		return &Encoder{buf: make([]byte, 0, 64*1024)}
+	},
+}
+
+// Encoder append RowBinary values to a byte slice.
+// Append methods don't return errors, first error is recorded and returned by Err and WriteTo.
+type Encoder struct {
+	buf []byte
+	err error
+}
+
+// NewEncoder create encoder with buffer capacity size
+func NewEncoder(size int) *Encoder {
+	return &Encoder{buf: make([]byte, 0, size)}
+}
+
+// GetEncoder return reset encoder from pool
+func GetEncoder() *Encoder {
+	return encoderPool.Get().(*Encoder)
+}
+
+// PutEncoder reset encoder and return it to pool
+func PutEncoder(e *Encoder) {
+	if cap(e.buf) > maxPooledSize {
+		return
+	}
+	e.Reset()
+	encoderPool.Put(e)
+}
+
+// Err return first error
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Bytes return encoded data (valid until next write or Reset)
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Len return encoded data length
+func (e *Encoder) Len() int {
+	return len(e.buf)
+}
+
+// Reset clear buffer and error (buffer capacity is kept)
+func (e *Encoder) Reset() {
+	e.buf = e.buf[:0]
+	e.err = nil
+}
+
+// Truncate discard all but the first n encoded bytes (for rollback of partially encoded row)
+func (e *Encoder) Truncate(n int) {
+	e.buf = e.buf[:n]
+}
+
+// WriteTo write encoded data to w and clear buffer (error is sticky)
+func (e *Encoder) WriteTo(w io.Writer) (int64, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := w.Write(e.buf)
+	if err != nil {
+		e.err = err
+		return int64(n), err
+	}
+	e.buf = e.buf[:0]
+	return int64(n), nil
+}
+
+// Write append raw (already encoded) bytes, so Encoder can be used as Writer target
+func (e *Encoder) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	return len(p), nil
+}
+
+func (e *Encoder) WriteUint8(value uint8) {
+	e.buf = append(e.buf, value)
+}
+
+func (e *Encoder) WriteUint16(value uint16) {
+	e.buf = append(e.buf, byte(value), byte(value>>8))
+}
+
+func (e *Encoder) WriteUint32(value uint32) {
+	e.buf = append(e.buf, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+}
+
+func (e *Encoder) WriteUint64(value uint64) {
+	e.buf = append(e.buf,
+		byte(value), byte(value>>8), byte(value>>16), byte(value>>24),
+		byte(value>>32), byte(value>>40), byte(value>>48), byte(value>>56),
+	)
+}
+
+func (e *Encoder) WriteInt8(value int8) {
+	e.WriteUint8(uint8(value))
+}
+
+func (e *Encoder) WriteInt16(value int16) {
+	e.WriteUint16(uint16(value))
+}
+
+func (e *Encoder) WriteInt32(value int32) {
+	e.WriteUint32(uint32(value))
+}
+
+func (e *Encoder) WriteInt64(value int64) {
+	e.WriteUint64(uint64(value))
+}
+
+func (e *Encoder) WriteFloat32(value float32) {
+	e.WriteUint32(math.Float32bits(value))
+}
+
+func (e *Encoder) WriteFloat64(value float64) {
+	e.WriteUint64(math.Float64bits(value))
+}
+
+func (e *Encoder) WriteNullableUint32(value uint32) {
+	if value == NullUint32 {
+		e.WriteUint8(1)
+	} else {
+		e.WriteUint8(0)
+		e.WriteUint32(value)
+	}
+}
+
+func (e *Encoder) WriteNullableFloat64(value float64) {
+	if math.IsNaN(value) {
+		e.WriteUint8(1)
+	} else {
+		e.WriteUint8(0)
+		e.WriteFloat64(value)
+	}
+}
+
+func (e *Encoder) WriteDate(value time.Time) {
+	e.WriteUint16(DateToUint16(value))
+}
+
+func (e *Encoder) WriteDateTime(value time.Time) {
+	e.WriteUint32(uint32(value.Unix()))
+}
+
+func (e *Encoder) WriteDateTime64(value time.Time, precision int) {
+	if precision < 0 || precision > 9 {
+		e.setErr(fmt.Errorf("%w: %d", ErrPrecision, precision))
+		return
+	}
+	e.WriteInt64(value.UnixNano() / pow10[9-precision])
+}
+
+func (e *Encoder) WriteUvarint(value uint64) {
+	e.buf = appendUvarint(e.buf, value)
+}
+
+func (e *Encoder) WriteBytes(value []byte) {
+	e.buf = appendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+func (e *Encoder) WriteString(value string) {
+	e.buf = appendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+func (e *Encoder) WriteFixedString(value string, n int) {
+	if len(value) > n {
+		e.setErr(fmt.Errorf("%w: length %d, want %d or less", ErrFixedStringLength, len(value), n))
+		return
+	}
+	e.buf = append(e.buf, stringutils.UnsafeStringBytes(&value)...)
+	for i := len(value); i < n; i++ {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *Encoder) WriteStringList(value []string) {
+	e.WriteUvarint(uint64(len(value)))
+	for i := range value {
+		e.WriteString(value[i])
+	}
+}
+
+func (e *Encoder) setErr(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func appendUvarint(buf []byte, value uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], value)
+	return append(buf, tmp[:n]...)
+}
@@ -0,0 +1,211 @@
+package RowBinary
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoder(t *testing.T) {
+	date := time.Date(2021, 2, 3, 4, 5, 6, 7000000, time.UTC)
+
+	var want bytes.Buffer
+	w := NewWriter(&want)
+	w.WriteUint8(1)
+	w.WriteUint16(math.MaxUint16)
+	w.WriteUint32(math.MaxUint32 - 1)
+	w.WriteUint64(math.MaxUint64)
+	w.WriteInt8(math.MinInt8)
+	w.WriteInt16(-2)
+	w.WriteInt32(math.MinInt32)
+	w.WriteInt64(-3)
+	w.WriteFloat32(1.5)
+	w.WriteFloat64(-2.5)
+	w.WriteNullableUint32(NullUint32)
+	w.WriteNullableUint32(4)
+	w.WriteNullableFloat64(math.NaN())
+	w.WriteNullableFloat64(5)
+	w.WriteDate(date)
+	w.WriteDateTime(date)
+	w.WriteDateTime64(date, 3)
+	w.WriteUvarint(300)
+	w.WriteBytes([]byte("bytes"))
+	w.WriteString("string")
+	w.WriteFixedString("ab", 4)
+	w.WriteStringList([]string{"a=1", "b=2"})
+	w.Write([]byte{1, 2, 3})
+
+	e := NewEncoder(0)
+	e.WriteUint8(1)
+	e.WriteUint16(math.MaxUint16)
+	e.WriteUint32(math.MaxUint32 - 1)
+	e.WriteUint64(math.MaxUint64)
+	e.WriteInt8(math.MinInt8)
+	e.WriteInt16(-2)
+	e.WriteInt32(math.MinInt32)
+	e.WriteInt64(-3)
+	e.WriteFloat32(1.5)
+	e.WriteFloat64(-2.5)
+	e.WriteNullableUint32(NullUint32)
+	e.WriteNullableUint32(4)
+	e.WriteNullableFloat64(math.NaN())
+	e.WriteNullableFloat64(5)
+	e.WriteDate(date)
+	e.WriteDateTime(date)
+	e.WriteDateTime64(date, 3)
+	e.WriteUvarint(300)
+	e.WriteBytes([]byte("bytes"))
+	e.WriteString("string")
+	e.WriteFixedString("ab", 4)
+	e.WriteStringList([]string{"a=1", "b=2"})
+	e.Write([]byte{1, 2, 3})
+
+	require.NoError(t, e.Err())
+	assert.Equal(t, want.Bytes(), e.Bytes())
+	assert.Equal(t, want.Len(), e.Len())
+
+	var got bytes.Buffer
+	n, err := e.WriteTo(&got)
+	require.NoError(t, err)
+	assert.Equal(t, int64(want.Len()), n)
+	assert.Equal(t, want.Bytes(), got.Bytes())
+	assert.Equal(t, 0, e.Len())
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestEncoderStickyError(t *testing.T) {
+	e := NewEncoder(16)
+	e.WriteString("test")
+	e.WriteFixedString("abcde", 4)
+	e.WriteDateTime64(time.Now(), 10)
+	e.WriteUint32(1)
+	assert.ErrorIs(t, e.Err(), ErrFixedStringLength)
+
+	var buf bytes.Buffer
+	_, err := e.WriteTo(&buf)
+	assert.ErrorIs(t, err, ErrFixedStringLength)
+	assert.Equal(t, 0, buf.Len())
+
+	e.Reset()
+	assert.NoError(t, e.Err())
+	assert.Equal(t, 0, e.Len())
+
+	writeErr := errors.New("write failed")
+	e.WriteUint32(1)
+	_, err = e.WriteTo(errWriter{writeErr})
+	assert.Equal(t, writeErr, err)
+	_, err = e.WriteTo(&buf)
+	assert.Equal(t, writeErr, err)
+	assert.Equal(t, writeErr, e.Err())
+}
+
+func TestEncoderPool(t *testing.T) {
+	e := GetEncoder()
+	e.WriteString("test")
+	e.WriteFixedString("abcde", 4)
+	PutEncoder(e)
+
+	e = GetEncoder()
+	defer PutEncoder(e)
+	assert.NoError(t, e.Err())
+	assert.Equal(t, 0, e.Len())
+}
+
+func TestEncoderTruncate(t *testing.T) {
+	e := NewEncoder(16)
+	e.WriteString("test")
+	n := e.Len()
+	e.WriteUint64(1)
+	e.Truncate(n)
+	assert.Equal(t, []byte{4, 't', 'e', 's', 't'}, e.Bytes())
+}
+
+func benchmarkMetrics() ([]string, [][]string) {
+	paths := make([]string, 1000)
+	tags := make([][]string, len(paths))
+	for i := range paths {
+		paths[i] = "test.metric.path" + strconv.Itoa(i) + "?dc=dc1&host=host" + strconv.Itoa(i) + "&instance=instance"
+		tags[i] = []string{"__name__=test.metric.path" + strconv.Itoa(i), "dc=dc1", "host=host" + strconv.Itoa(i), "instance=instance"}
+	}
+	return paths, tags
+}
+
+// BenchmarkWriterBuffer is a bytes.Buffer + NewWriter per metric pattern
+func BenchmarkWriterBuffer(b *testing.B) {
+	paths, tags := benchmarkMetrics()
+	date := time.Now()
+	var out bytes.Buffer
+	out.Grow(1024 * 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		out.Reset()
+		var tagsBuf bytes.Buffer
+		var buf bytes.Buffer
+		tagsBuf.Grow(4096)
+		buf.Grow(512 * 1024)
+		for i := range paths {
+			tagsBuf.Reset()
+			buf.Reset()
+			NewWriter(&tagsBuf).WriteStringList(tags[i])
+			w := NewWriter(&buf)
+			for _, tag1 := range tags[i] {
+				w.WriteDate(date)
+				w.WriteString(tag1)
+				w.WriteString(paths[i])
+				w.Write(tagsBuf.Bytes())
+				w.WriteUint32(1)
+			}
+			out.Write(buf.Bytes())
+		}
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	paths, tags := benchmarkMetrics()
+	date := time.Now()
+	var out bytes.Buffer
+	out.Grow(1024 * 1024)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		out.Reset()
+		e := GetEncoder()
+		for i := range paths {
+			var start, end int
+			for j, tag1 := range tags[i] {
+				e.WriteDate(date)
+				e.WriteString(tag1)
+				e.WriteString(paths[i])
+				if j == 0 {
+					start = e.Len()
+					e.WriteStringList(tags[i])
+					end = e.Len()
+				} else {
+					e.Write(e.Bytes()[start:end])
+				}
+				e.WriteUint32(1)
+			}
+			if e.Len() >= 512*1024 {
+				e.WriteTo(&out)
+			}
+		}
+		e.WriteTo(&out)
+		PutEncoder(e)
+	}
+}
@@ -4,3 +4,9 @@ import "errors"
 
 var ErrEOF = errors.New("unexcepted end")
 var ErrUvarintOverflow = errors.New("varint overflow")
+var ErrLengthOverflow = errors.New("length overflow")
+var ErrHeaderMismatch = errors.New("header mismatch")
+var ErrFixedStringLength = errors.New("invalid FixedString length")
+var ErrDecimalOverflow = errors.New("decimal overflow")
+var ErrPrecision = errors.New("invalid DateTime64 precision")
+var ErrUnsupportedType = errors.New("unsupported type")
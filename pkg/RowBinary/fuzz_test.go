@@ -0,0 +1,285 @@
+package RowBinary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readers is a list of all Reader methods (for fuzzing)
+var readers = map[string]func(r *Reader) error{
+	"ReadUint8":                func(r *Reader) error { _, err := r.ReadUint8(); return err },
+	"ReadUint16":               func(r *Reader) error { _, err := r.ReadUint16(); return err },
+	"ReadUint32":               func(r *Reader) error { _, err := r.ReadUint32(); return err },
+	"ReadUint64":               func(r *Reader) error { _, err := r.ReadUint64(); return err },
+	"ReadInt8":                 func(r *Reader) error { _, err := r.ReadInt8(); return err },
+	"ReadInt16":                func(r *Reader) error { _, err := r.ReadInt16(); return err },
+	"ReadInt32":                func(r *Reader) error { _, err := r.ReadInt32(); return err },
+	"ReadInt64":                func(r *Reader) error { _, err := r.ReadInt64(); return err },
+	"ReadFloat32":              func(r *Reader) error { _, err := r.ReadFloat32(); return err },
+	"ReadFloat64":              func(r *Reader) error { _, err := r.ReadFloat64(); return err },
+	"ReadIsNull":               func(r *Reader) error { _, err := r.ReadIsNull(); return err },
+	"ReadNullableUint32":       func(r *Reader) error { _, err := r.ReadNullableUint32(); return err },
+	"ReadNullableFloat64":      func(r *Reader) error { _, err := r.ReadNullableFloat64(); return err },
+	"ReadNullableString":       func(r *Reader) error { _, _, err := r.ReadNullableString(); return err },
+	"ReadString":               func(r *Reader) error { _, err := r.ReadString(); return err },
+	"ReadStringBytes":          func(r *Reader) error { _, err := r.ReadStringBytes(); return err },
+	"ReadBytes":                func(r *Reader) error { _, err := r.ReadBytes(); return err },
+	"ReadLowCardinalityString": func(r *Reader) error { _, err := r.ReadLowCardinalityString(); return err },
+	"ReadDate":                 func(r *Reader) error { _, err := r.ReadDate(); return err },
+	"ReadDateTime":             func(r *Reader) error { _, err := r.ReadDateTime(); return err },
+	"ReadDateTime64":           func(r *Reader) error { _, err := r.ReadDateTime64(3); return err },
+	"ReadUUID":                 func(r *Reader) error { _, err := r.ReadUUID(); return err },
+	"ReadFixedString":          func(r *Reader) error { _, err := r.ReadFixedString(5); return err },
+	"ReadDecimal32":            func(r *Reader) error { _, err := r.ReadDecimal32(2); return err },
+	"ReadDecimal64":            func(r *Reader) error { _, err := r.ReadDecimal64(4); return err },
+	"ReadDecimal64Raw":         func(r *Reader) error { _, err := r.ReadDecimal64Raw(4); return err },
+	"ReadDecimal128":           func(r *Reader) error { _, err := r.ReadDecimal128(); return err },
+	"ReadMapStringString":      func(r *Reader) error { _, err := r.ReadMapStringString(); return err },
+	"ReadStringList":           func(r *Reader) error { _, err := r.ReadStringList(); return err },
+	"ReadUint32List":           func(r *Reader) error { _, err := r.ReadUint32List(); return err },
+	"ReadNullableUint32List":   func(r *Reader) error { _, err := r.ReadNullableUint32List(); return err },
+	"ReadFloat64List":          func(r *Reader) error { _, err := r.ReadFloat64List(); return err },
+	"ReadNullableFloat64List":  func(r *Reader) error { _, err := r.ReadNullableFloat64List(); return err },
+	"ReadHeader":               func(r *Reader) error { _, _, err := r.ReadHeader(); return err },
+	"Unmarshal":                func(r *Reader) error { var row testRow; return Unmarshal(r, &row) },
+}
+
+func addFuzzSeeds(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0})
+	f.Add([]byte{1, 0})
+	f.Add([]byte{3, 'a', 'b', 'c'})
+	f.Add([]byte{0x80, 0x80, 0x80})
+	f.Add(bytes.Repeat([]byte{0xff}, 11))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0x0f})
+	f.Add([]byte{2, 1, 'a', 1, 'b', 1, 1, 0, 0, 0})
+}
+
+// FuzzReader read arbitrary input with all Reader methods, must not panic or hang
+func FuzzReader(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for name, read := range readers {
+			for _, rdr := range []io.Reader{bytes.NewReader(data), iotest.OneByteReader(bytes.NewReader(data))} {
+				r := NewReaderBuffered(rdr, 0)
+				for {
+					offset := r.Offset()
+					if err := read(r); err != nil {
+						break
+					}
+					if r.Offset() <= offset && name != "ReadFixedString" {
+						t.Fatalf("%s: offset not advanced", name)
+					}
+					if r.Offset() > int64(len(data)) {
+						t.Fatalf("%s: offset %d beyond input length %d", name, r.Offset(), len(data))
+					}
+				}
+			}
+		}
+	})
+}
+
+// FuzzReadUvarint compare readUvarint with encoding/binary
+func FuzzReadUvarint(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, n := binary.Uvarint(data)
+		got, err := NewReaderBuffered(bytes.NewReader(data), 0).readUvarint()
+		switch {
+		case n > 0:
+			if err != nil || got != want {
+				t.Fatalf("readUvarint(%v) = %d, %v, want %d", data, got, err, want)
+			}
+		case n == 0 && len(data) >= binary.MaxVarintLen64:
+			// binary.Uvarint want more data, but varint can't be longer
+			if err != ErrUvarintOverflow {
+				t.Fatalf("readUvarint(%v) = %d, %v, want overflow", data, got, err)
+			}
+		case n == 0:
+			if err != io.EOF && err != ErrEOF {
+				t.Fatalf("readUvarint(%v) = %d, %v, want EOF", data, got, err)
+			}
+		default:
+			if err != ErrUvarintOverflow {
+				t.Fatalf("readUvarint(%v) = %d, %v, want overflow", data, got, err)
+			}
+		}
+	})
+}
+
+// FuzzStringList write and read string list
+func FuzzStringList(f *testing.F) {
+	f.Add("", "", 0)
+	f.Add("a", "bc", 3)
+	f.Fuzz(func(t *testing.T, a, b string, n int) {
+		if n < 0 || n > 100 {
+			n %= 100
+			if n < 0 {
+				n = -n
+			}
+		}
+		list := make([]string, n)
+		for i := range list {
+			if i%2 == 0 {
+				list[i] = a
+			} else {
+				list[i] = b
+			}
+		}
+		var buf bytes.Buffer
+		require.NoError(t, NewWriter(&buf).WriteStringList(list))
+		got, err := NewReaderBuffered(iotest.HalfReader(&buf), 0).ReadStringList()
+		require.NoError(t, err)
+		assert.Equal(t, list, got)
+	})
+}
+
+func TestPropertyUvarint(t *testing.T) {
+	f := func(values []uint64) bool {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		for _, v := range values {
+			if _, err := w.WriteUvarint(v); err != nil {
+				return false
+			}
+		}
+		r := NewReaderBuffered(iotest.OneByteReader(&buf), 0)
+		for _, v := range values {
+			if got, err := r.readUvarint(); err != nil || got != v {
+				return false
+			}
+		}
+		_, err := r.readUvarint()
+		return err == io.EOF
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// propRow is a row with all types, supported by testing/quick generator
+type propRow struct {
+	U8      uint8             `ch:"U8,UInt8"`
+	U16     uint16            `ch:"U16,UInt16"`
+	U32     uint32            `ch:"U32,UInt32"`
+	U64     uint64            `ch:"U64,UInt64"`
+	I8      int8              `ch:"I8,Int8"`
+	I16     int16             `ch:"I16,Int16"`
+	I32     int32             `ch:"I32,Int32"`
+	I64     int64             `ch:"I64,Int64"`
+	F32     float32           `ch:"F32,Float32"`
+	F64     float64           `ch:"F64,Float64"`
+	S       string            `ch:"S,String"`
+	B       []byte            `ch:"B,String"`
+	UUID    [16]byte          `ch:"UUID,UUID"`
+	Tags    []string          `ch:"Tags,Array(String)"`
+	Nested  [][]string        `ch:"Nested,Array(Array(String))"`
+	Values  [][]float64       `ch:"Values,Array(Array(Float64))"`
+	Labels  map[string]string `ch:"Labels,Map(String, String)"`
+	Comment *string           `ch:"Comment,Nullable(String)"`
+	Level   *uint32           `ch:"Level,Nullable(UInt32)"`
+}
+
+// normalize replace nil slices and maps with empty (decoder always return non-nil)
+func (p *propRow) normalize() {
+	if p.B == nil {
+		p.B = []byte{}
+	}
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	if p.Nested == nil {
+		p.Nested = [][]string{}
+	}
+	for i := range p.Nested {
+		if p.Nested[i] == nil {
+			p.Nested[i] = []string{}
+		}
+	}
+	if p.Values == nil {
+		p.Values = [][]float64{}
+	}
+	for i := range p.Values {
+		if p.Values[i] == nil {
+			p.Values[i] = []float64{}
+		}
+	}
+	if p.Labels == nil {
+		p.Labels = map[string]string{}
+	}
+}
+
+func TestPropertyRows(t *testing.T) {
+	f := func(rows []propRow) bool {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		for i := range rows {
+			if err := Marshal(w, &rows[i]); err != nil {
+				t.Log(err)
+				return false
+			}
+		}
+		r := NewReaderBuffered(iotest.HalfReader(&buf), 0)
+		for i := range rows {
+			var got propRow
+			if err := Unmarshal(r, &got); err != nil {
+				t.Log(err)
+				return false
+			}
+			rows[i].normalize()
+			if !reflect.DeepEqual(rows[i], got) {
+				// NaN is not equal to itself
+				if math.IsNaN(float64(rows[i].F32)) || math.IsNaN(rows[i].F64) {
+					continue
+				}
+				t.Logf("row %d: %+v, got %+v", i, rows[i], got)
+				return false
+			}
+		}
+		var got propRow
+		return Unmarshal(r, &got) == io.EOF
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPropertyEncoderWriter(t *testing.T) {
+	f := func(u32 []uint32, f64 []float64, s []string, nested [][]string) bool {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		e := NewEncoder(0)
+
+		w.Uint32List(u32)
+		w.Float64List(f64)
+		w.WriteStringList(s)
+		_, _ = w.WriteUvarint(uint64(len(nested)))
+		e.WriteUvarint(uint64(len(u32)))
+		for _, v := range u32 {
+			e.WriteUint32(v)
+		}
+		e.WriteUvarint(uint64(len(f64)))
+		for _, v := range f64 {
+			e.WriteFloat64(v)
+		}
+		e.WriteStringList(s)
+		e.WriteUvarint(uint64(len(nested)))
+		for _, list := range nested {
+			w.WriteStringList(list)
+			e.WriteStringList(list)
+		}
+		return bytes.Equal(buf.Bytes(), e.Bytes())
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
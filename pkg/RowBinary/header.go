@@ -0,0 +1,83 @@
+package RowBinary
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WriteHeader write RowBinaryWithNamesAndTypes header: columns count, columns names, columns types
+func (w *Writer) WriteHeader(names, types []string) error {
+	if len(names) != len(types) {
+		return fmt.Errorf("columns names count %d not equal to types count %d", len(names), len(types))
+	}
+	if _, err := w.WriteUvarint(uint64(len(names))); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := w.WriteString(name); err != nil {
+			return err
+		}
+	}
+	for _, typ := range types {
+		if err := w.WriteString(typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadHeader read RowBinaryWithNamesAndTypes header
+func (r *Reader) ReadHeader() (names []string, types []string, err error) {
+	n, err := r.readLen()
+	if err != nil {
+		return nil, nil, err
+	}
+	names = make([]string, 0, capHint(n))
+	for i := 0; i < n; i++ {
+		s, err := r.ReadString()
+		if err != nil {
+			return names, nil, noEOF(err)
+		}
+		names = append(names, s)
+	}
+	types = make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		s, err := r.ReadString()
+		if err != nil {
+			return names, types, noEOF(err)
+		}
+		types = append(types, s)
+	}
+	return names, types, nil
+}
+
+// normalizeType remove spaces from type, like "Map(String, String)"
+func normalizeType(typ string) string {
+	return strings.ReplaceAll(typ, " ", "")
+}
+
+// ValidateHeader compare columns names and types with expected
+func ValidateHeader(names, types, wantNames, wantTypes []string) error {
+	if len(names) != len(wantNames) {
+		return fmt.Errorf("%w: got %d columns (%s), want %d (%s)", ErrHeaderMismatch,
+			len(names), strings.Join(names, ", "), len(wantNames), strings.Join(wantNames, ", "))
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			return fmt.Errorf("%w: column %d name is '%s', want '%s'", ErrHeaderMismatch, i+1, names[i], wantNames[i])
+		}
+		if normalizeType(types[i]) != normalizeType(wantTypes[i]) {
+			return fmt.Errorf("%w: column %d (%s) type is '%s', want '%s'", ErrHeaderMismatch, i+1, names[i], types[i], wantTypes[i])
+		}
+	}
+	return nil
+}
+
+// ReadAndValidateHeader read RowBinaryWithNamesAndTypes header and compare it with expected columns
+func (r *Reader) ReadAndValidateHeader(wantNames, wantTypes []string) error {
+	names, types, err := r.ReadHeader()
+	if err != nil {
+		return err
+	}
+	return ValidateHeader(names, types, wantNames, wantTypes)
+}
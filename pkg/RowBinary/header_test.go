@@ -0,0 +1,64 @@
+package RowBinary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteHeader(t *testing.T) {
+	names := []string{"Date", "Tag1", "Path", "Tags", "Version"}
+	types := []string{"Date", "String", "String", "Array(String)", "UInt32"}
+
+	tests := []struct {
+		name      string
+		wantNames []string
+		wantTypes []string
+		wantErr   string
+	}{
+		{name: "valid", wantNames: names, wantTypes: types},
+		{
+			name:      "columns count",
+			wantNames: names[:4], wantTypes: types[:4],
+			wantErr: "header mismatch: got 5 columns (Date, Tag1, Path, Tags, Version), want 4 (Date, Tag1, Path, Tags)",
+		},
+		{
+			name:      "column name",
+			wantNames: []string{"Date", "Path", "Tag1", "Tags", "Version"}, wantTypes: types,
+			wantErr: "header mismatch: column 2 name is 'Tag1', want 'Path'",
+		},
+		{
+			name:      "column type",
+			wantNames: names, wantTypes: []string{"Date", "String", "String", "Array(String)", "UInt64"},
+			wantErr: "header mismatch: column 5 (Version) type is 'UInt32', want 'UInt64'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			err := w.WriteHeader(names, types)
+			assert.NoError(t, err)
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			err = r.ReadAndValidateHeader(tt.wantNames, tt.wantTypes)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, ErrHeaderMismatch))
+				assert.EqualError(t, err, tt.wantErr)
+			}
+
+			// want EOF
+			n, err := r.ReadUint16()
+			assert.Equal(t, io.EOF, err)
+			assert.Equal(t, uint16(0), n)
+		})
+	}
+
+	assert.Error(t, NewWriter(&bytes.Buffer{}).WriteHeader(names, types[:1]))
+	assert.NoError(t, ValidateHeader([]string{"Labels"}, []string{"Map(String, String)"}, []string{"Labels"}, []string{"Map(String,String)"}))
+}
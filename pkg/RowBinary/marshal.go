@@ -0,0 +1,585 @@
+package RowBinary
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Codec is a row layout, derived from struct fields with `ch:"Column,Type"` tags.
+// Fields without ch tag (or with `ch:"-"`) are skipped.
+//
+//	type TaggedRow struct {
+//		Date    time.Time `ch:"Date,Date"`
+//		Path    string    `ch:"Path,String"`
+//		Tags    []string  `ch:"Tags,Array(String)"`
+//		Version uint32    `ch:"Version,UInt32"`
+//	}
+//
+// Nullable columns are pointer fields (nil is NULL), Nullable(UInt32) and Nullable(Float64)
+// also can be uint32 (NullUint32 is NULL) and float64 (NaN is NULL) fields.
+// Decimal32 and Decimal64 columns are float64 fields, Decimal64 also can be int64 field (exact scaled value).
+type Codec struct {
+	typ    reflect.Type
+	names  []string
+	types  []string
+	fields []fieldCodec
+}
+
+type fieldCodec struct {
+	index int
+	valueCodec
+}
+
+type valueCodec struct {
+	enc func(w *Writer, v reflect.Value) error
+	dec func(r *Reader, v reflect.Value) error
+}
+
+var codecs sync.Map // reflect.Type -> *Codec
+
+// CodecOf return cached codec for struct (or pointer to struct) type of v
+func CodecOf(v interface{}) (*Codec, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %v, want struct", ErrUnsupportedType, t)
+	}
+	if c, ok := codecs.Load(t); ok {
+		return c.(*Codec), nil
+	}
+	c, err := newCodec(t)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := codecs.LoadOrStore(t, c)
+	return actual.(*Codec), nil
+}
+
+// MustCodecOf is like CodecOf, but panics on error (for package level row layouts)
+func MustCodecOf(v interface{}) *Codec {
+	c, err := CodecOf(v)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func newCodec(t reflect.Type) (*Codec, error) {
+	c := &Codec{typ: t}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("ch")
+		if !ok || tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		n := strings.IndexByte(tag, ',')
+		if n <= 0 || n == len(tag)-1 {
+			return nil, fmt.Errorf("%s.%s: invalid ch tag '%s', want Column,Type", t.Name(), f.Name, tag)
+		}
+		name := tag[:n]
+		chType := strings.TrimSpace(tag[n+1:])
+		vc, err := newValueCodec(chType, f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		c.names = append(c.names, name)
+		c.types = append(c.types, chType)
+		c.fields = append(c.fields, fieldCodec{index: i, valueCodec: vc})
+	}
+	if len(c.fields) == 0 {
+		return nil, fmt.Errorf("%s: no fields with ch tag", t.Name())
+	}
+	return c, nil
+}
+
+// Names return columns names
+func (c *Codec) Names() []string {
+	return c.names
+}
+
+// Types return columns types
+func (c *Codec) Types() []string {
+	return c.types
+}
+
+// Columns return comma-separated columns names (for INSERT query)
+func (c *Codec) Columns() string {
+	return strings.Join(c.names, ", ")
+}
+
+// WriteHeader write RowBinaryWithNamesAndTypes header
+func (c *Codec) WriteHeader(w *Writer) error {
+	return w.WriteHeader(c.names, c.types)
+}
+
+// ReadHeader read RowBinaryWithNamesAndTypes header and validate it against codec columns
+func (c *Codec) ReadHeader(r *Reader) error {
+	return r.ReadAndValidateHeader(c.names, c.types)
+}
+
+func (c *Codec) structValue(v interface{}, ptr bool) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, fmt.Errorf("%w: nil %v", ErrUnsupportedType, rv.Type())
+		}
+		rv = rv.Elem()
+	} else if ptr {
+		return rv, fmt.Errorf("%w: %v, want pointer", ErrUnsupportedType, rv.Type())
+	}
+	if rv.Type() != c.typ {
+		return rv, fmt.Errorf("%w: %v, want %v", ErrUnsupportedType, rv.Type(), c.typ)
+	}
+	return rv, nil
+}
+
+// Marshal write row v (struct or pointer to struct of the codec type)
+func (c *Codec) Marshal(w *Writer, v interface{}) error {
+	rv, err := c.structValue(v, false)
+	if err != nil {
+		return err
+	}
+	for i := range c.fields {
+		if err = c.fields[i].enc(w, rv.Field(c.fields[i].index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unmarshal read row into v (pointer to struct of the codec type)
+func (c *Codec) Unmarshal(r *Reader, v interface{}) error {
+	rv, err := c.structValue(v, true)
+	if err != nil {
+		return err
+	}
+	for i := range c.fields {
+		if err = c.fields[i].dec(r, rv.Field(c.fields[i].index)); err != nil {
+			if i > 0 {
+				// partially read row
+				return noEOF(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Marshal write row v (struct or pointer to struct with ch tags)
+func Marshal(w *Writer, v interface{}) error {
+	c, err := CodecOf(v)
+	if err != nil {
+		return err
+	}
+	return c.Marshal(w, v)
+}
+
+// Unmarshal read row into v (pointer to struct with ch tags)
+func Unmarshal(r *Reader, v interface{}) error {
+	c, err := CodecOf(v)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(r, v)
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+	uuidType  = reflect.TypeOf([16]byte{})
+	mapType   = reflect.TypeOf(map[string]string(nil))
+)
+
+// typeArgs return arguments of parametrized type, like Array(String) or Decimal64(3)
+func typeArgs(chType, prefix string) (string, bool) {
+	if strings.HasPrefix(chType, prefix+"(") && strings.HasSuffix(chType, ")") {
+		return strings.TrimSpace(chType[len(prefix)+1 : len(chType)-1]), true
+	}
+	return "", false
+}
+
+func unsupported(chType string, t reflect.Type) error {
+	return fmt.Errorf("%w: %s for %v", ErrUnsupportedType, chType, t)
+}
+
+func newValueCodec(chType string, t reflect.Type) (valueCodec, error) {
+	if arg, ok := typeArgs(chType, "LowCardinality"); ok {
+		return newValueCodec(arg, t)
+	}
+	if arg, ok := typeArgs(chType, "Nullable"); ok {
+		return newNullableCodec(arg, t)
+	}
+	if arg, ok := typeArgs(chType, "Array"); ok {
+		if t.Kind() != reflect.Slice || t == bytesType {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		elem, err := newValueCodec(arg, t.Elem())
+		if err != nil {
+			return elem, err
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error {
+				n := v.Len()
+				if _, err := w.WriteUvarint(uint64(n)); err != nil {
+					return err
+				}
+				for i := 0; i < n; i++ {
+					if err := elem.enc(w, v.Index(i)); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			dec: func(r *Reader, v reflect.Value) error {
+				n, err := r.readLen()
+				if err != nil {
+					return err
+				}
+				s := reflect.MakeSlice(t, 0, capHint(n))
+				zero := reflect.Zero(t.Elem())
+				for i := 0; i < n; i++ {
+					s = reflect.Append(s, zero)
+					if err = elem.dec(r, s.Index(i)); err != nil {
+						return noEOF(err)
+					}
+				}
+				v.Set(s)
+				return nil
+			},
+		}, nil
+	}
+	if arg, ok := typeArgs(chType, "FixedString"); ok {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || t.Kind() != reflect.String {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteFixedString(v.String(), n) },
+			dec: func(r *Reader, v reflect.Value) error {
+				s, err := r.ReadFixedString(n)
+				v.SetString(s)
+				return err
+			},
+		}, nil
+	}
+	if arg, ok := typeArgs(chType, "DateTime64"); ok {
+		precision, err := strconv.Atoi(arg)
+		if err != nil || precision < 0 || precision > 9 || t != timeType {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error {
+				return w.WriteDateTime64(v.Interface().(time.Time), precision)
+			},
+			dec: func(r *Reader, v reflect.Value) error {
+				tm, err := r.ReadDateTime64(precision)
+				v.Set(reflect.ValueOf(tm))
+				return err
+			},
+		}, nil
+	}
+	for _, decimal := range []string{"Decimal32", "Decimal64"} {
+		if arg, ok := typeArgs(chType, decimal); ok {
+			scale, err := strconv.Atoi(arg)
+			maxScale := Decimal64Precision
+			if decimal == "Decimal32" {
+				maxScale = Decimal32Precision
+			}
+			if err != nil || scale < 0 || scale > maxScale {
+				return valueCodec{}, unsupported(chType, t)
+			}
+			if decimal == "Decimal64" && t.Kind() == reflect.Int64 {
+				// already scaled value, exact for all digits
+				return valueCodec{
+					enc: func(w *Writer, v reflect.Value) error { return w.WriteDecimal64Raw(v.Int(), scale) },
+					dec: func(r *Reader, v reflect.Value) error {
+						n, err := r.ReadDecimal64Raw(scale)
+						v.SetInt(n)
+						return err
+					},
+				}, nil
+			}
+			if t.Kind() != reflect.Float64 {
+				return valueCodec{}, unsupported(chType, t)
+			}
+			if decimal == "Decimal32" {
+				return valueCodec{
+					enc: func(w *Writer, v reflect.Value) error { return w.WriteDecimal32(v.Float(), scale) },
+					dec: func(r *Reader, v reflect.Value) error {
+						f, err := r.ReadDecimal32(scale)
+						v.SetFloat(f)
+						return err
+					},
+				}, nil
+			}
+			return valueCodec{
+				enc: func(w *Writer, v reflect.Value) error { return w.WriteDecimal64(v.Float(), scale) },
+				dec: func(r *Reader, v reflect.Value) error {
+					f, err := r.ReadDecimal64(scale)
+					v.SetFloat(f)
+					return err
+				},
+			}, nil
+		}
+	}
+
+	switch chType {
+	case "Date", "DateTime":
+		dateTime := chType == "DateTime"
+		if t == timeType {
+			return valueCodec{
+				enc: func(w *Writer, v reflect.Value) error {
+					if dateTime {
+						return w.WriteDateTime(v.Interface().(time.Time))
+					}
+					return w.WriteDate(v.Interface().(time.Time))
+				},
+				dec: func(r *Reader, v reflect.Value) error {
+					var (
+						tm  time.Time
+						err error
+					)
+					if dateTime {
+						tm, err = r.ReadDateTime()
+					} else {
+						tm, err = r.ReadDate()
+					}
+					v.Set(reflect.ValueOf(tm))
+					return err
+				},
+			}, nil
+		}
+		if dateTime {
+			return newUintCodec(chType, t, reflect.Uint32)
+		}
+		return newUintCodec(chType, t, reflect.Uint16)
+	case "UInt8":
+		return newUintCodec(chType, t, reflect.Uint8)
+	case "UInt16":
+		return newUintCodec(chType, t, reflect.Uint16)
+	case "UInt32":
+		return newUintCodec(chType, t, reflect.Uint32)
+	case "UInt64":
+		return newUintCodec(chType, t, reflect.Uint64)
+	case "Int8":
+		return newIntCodec(chType, t, reflect.Int8)
+	case "Int16":
+		return newIntCodec(chType, t, reflect.Int16)
+	case "Int32":
+		return newIntCodec(chType, t, reflect.Int32)
+	case "Int64":
+		return newIntCodec(chType, t, reflect.Int64)
+	case "Float32":
+		if t.Kind() != reflect.Float32 {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteFloat32(float32(v.Float())) },
+			dec: func(r *Reader, v reflect.Value) error {
+				f, err := r.ReadFloat32()
+				v.SetFloat(float64(f))
+				return err
+			},
+		}, nil
+	case "Float64":
+		if t.Kind() != reflect.Float64 {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteFloat64(v.Float()) },
+			dec: func(r *Reader, v reflect.Value) error {
+				f, err := r.ReadFloat64()
+				v.SetFloat(f)
+				return err
+			},
+		}, nil
+	case "String":
+		if t == bytesType {
+			return valueCodec{
+				enc: func(w *Writer, v reflect.Value) error { return w.WriteBytes(v.Bytes()) },
+				dec: func(r *Reader, v reflect.Value) error {
+					b, err := r.ReadBytes()
+					v.SetBytes(b)
+					return err
+				},
+			}, nil
+		}
+		if t.Kind() != reflect.String {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteString(v.String()) },
+			dec: func(r *Reader, v reflect.Value) error {
+				s, err := r.ReadString()
+				v.SetString(s)
+				return err
+			},
+		}, nil
+	case "UUID":
+		if t != uuidType {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteUUID(v.Interface().([16]byte)) },
+			dec: func(r *Reader, v reflect.Value) error {
+				u, err := r.ReadUUID()
+				v.Set(reflect.ValueOf(u))
+				return err
+			},
+		}, nil
+	case "Map(String, String)", "Map(String,String)":
+		if t != mapType {
+			return valueCodec{}, unsupported(chType, t)
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error {
+				return w.WriteMapStringString(v.Interface().(map[string]string))
+			},
+			dec: func(r *Reader, v reflect.Value) error {
+				m, err := r.ReadMapStringString()
+				v.Set(reflect.ValueOf(m))
+				return err
+			},
+		}, nil
+	}
+	return valueCodec{}, unsupported(chType, t)
+}
+
+func newUintCodec(chType string, t reflect.Type, kind reflect.Kind) (valueCodec, error) {
+	if t.Kind() != kind {
+		return valueCodec{}, unsupported(chType, t)
+	}
+	vc := valueCodec{}
+	switch kind {
+	case reflect.Uint8:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteUint8(uint8(v.Uint())) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			u, err := r.ReadUint8()
+			v.SetUint(uint64(u))
+			return err
+		}
+	case reflect.Uint16:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteUint16(uint16(v.Uint())) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			u, err := r.ReadUint16()
+			v.SetUint(uint64(u))
+			return err
+		}
+	case reflect.Uint32:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteUint32(uint32(v.Uint())) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			u, err := r.ReadUint32()
+			v.SetUint(uint64(u))
+			return err
+		}
+	default:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteUint64(v.Uint()) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			u, err := r.ReadUint64()
+			v.SetUint(u)
+			return err
+		}
+	}
+	return vc, nil
+}
+
+func newIntCodec(chType string, t reflect.Type, kind reflect.Kind) (valueCodec, error) {
+	if t.Kind() != kind {
+		return valueCodec{}, unsupported(chType, t)
+	}
+	vc := valueCodec{}
+	switch kind {
+	case reflect.Int8:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteInt8(int8(v.Int())) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			i, err := r.ReadInt8()
+			v.SetInt(int64(i))
+			return err
+		}
+	case reflect.Int16:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteInt16(int16(v.Int())) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			i, err := r.ReadInt16()
+			v.SetInt(int64(i))
+			return err
+		}
+	case reflect.Int32:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteInt32(int32(v.Int())) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			i, err := r.ReadInt32()
+			v.SetInt(int64(i))
+			return err
+		}
+	default:
+		vc.enc = func(w *Writer, v reflect.Value) error { return w.WriteInt64(v.Int()) }
+		vc.dec = func(r *Reader, v reflect.Value) error {
+			i, err := r.ReadInt64()
+			v.SetInt(i)
+			return err
+		}
+	}
+	return vc, nil
+}
+
+func newNullableCodec(chType string, t reflect.Type) (valueCodec, error) {
+	if t.Kind() == reflect.Ptr {
+		elem, err := newValueCodec(chType, t.Elem())
+		if err != nil {
+			return elem, err
+		}
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error {
+				if v.IsNil() {
+					return w.WriteNull(true)
+				}
+				if err := w.WriteNull(false); err != nil {
+					return err
+				}
+				return elem.enc(w, v.Elem())
+			},
+			dec: func(r *Reader, v reflect.Value) error {
+				isNull, err := r.ReadIsNull()
+				if err != nil {
+					return err
+				}
+				if isNull {
+					v.Set(reflect.Zero(t))
+					return nil
+				}
+				p := reflect.New(t.Elem())
+				if err = elem.dec(r, p.Elem()); err != nil {
+					return err
+				}
+				v.Set(p)
+				return nil
+			},
+		}, nil
+	}
+	switch {
+	case chType == "UInt32" && t.Kind() == reflect.Uint32:
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteNullableUint32(uint32(v.Uint())) },
+			dec: func(r *Reader, v reflect.Value) error {
+				u, err := r.ReadNullableUint32()
+				v.SetUint(uint64(u))
+				return err
+			},
+		}, nil
+	case chType == "Float64" && t.Kind() == reflect.Float64:
+		return valueCodec{
+			enc: func(w *Writer, v reflect.Value) error { return w.WriteNullableFloat64(v.Float()) },
+			dec: func(r *Reader, v reflect.Value) error {
+				f, err := r.ReadNullableFloat64()
+				v.SetFloat(f)
+				return err
+			},
+		}, nil
+	}
+	return valueCodec{}, unsupported("Nullable("+chType+")", t)
+}
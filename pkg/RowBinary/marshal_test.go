@@ -0,0 +1,223 @@
+package RowBinary
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRow struct {
+	Date     time.Time         `ch:"Date,Date"`
+	Time     time.Time         `ch:"Time,DateTime"`
+	Time64   time.Time         `ch:"Time64,DateTime64(3)"`
+	Level    uint16            `ch:"Level,UInt16"`
+	Delta    int64             `ch:"Delta,Int64"`
+	Ratio    float32           `ch:"Ratio,Float32"`
+	Name     string            `ch:"Name,LowCardinality(String)"`
+	Raw      []byte            `ch:"Raw,String"`
+	Code     string            `ch:"Code,FixedString(3)"`
+	Price    float64           `ch:"Price,Decimal64(2)"`
+	ID       [16]byte          `ch:"ID,UUID"`
+	Tags     []string          `ch:"Tags,Array(String)"`
+	Values   []float64         `ch:"Values,Array(Nullable(Float64))"`
+	Nested   [][]uint32        `ch:"Nested,Array(Array(UInt32))"`
+	Labels   map[string]string `ch:"Labels,Map(String, String)"`
+	Count    uint32            `ch:"Count,Nullable(UInt32)"`
+	Comment  *string           `ch:"Comment,Nullable(String)"`
+	Missing  *int8             `ch:"Missing,Nullable(Int8)"`
+	Skipped  string            `ch:"-"`
+	internal string
+}
+
+func TestMarshal(t *testing.T) {
+	comment := "comment"
+	row := testRow{
+		Date:    time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC),
+		Time:    time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		Time64:  time.Date(2021, 2, 3, 4, 5, 6, 7000000, time.UTC),
+		Level:   3,
+		Delta:   -100,
+		Ratio:   0.5,
+		Name:    "name",
+		Raw:     []byte("raw"),
+		Code:    "ab",
+		Price:   -12.34,
+		ID:      [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Tags:    []string{"a=1", "b=2"},
+		Values:  []float64{1.5, 2},
+		Nested:  [][]uint32{{1}, {}, {2, 3}},
+		Labels:  map[string]string{"k": "v"},
+		Count:   NullUint32,
+		Comment: &comment,
+		Skipped: "skipped",
+	}
+
+	c, err := CodecOf(&row)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Date", "Time", "Time64", "Level", "Delta", "Ratio", "Name", "Raw", "Code", "Price",
+		"ID", "Tags", "Values", "Nested", "Labels", "Count", "Comment", "Missing",
+	}, c.Names())
+	assert.Equal(t, "LowCardinality(String)", c.Types()[6])
+	assert.Equal(t, "Map(String, String)", c.Types()[14])
+
+	wb := &bytes.Buffer{}
+	w := NewWriter(wb)
+	require.NoError(t, c.WriteHeader(w))
+	require.NoError(t, Marshal(w, row))
+	require.NoError(t, Marshal(w, &row))
+
+	r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+	require.NoError(t, c.ReadHeader(r))
+	for i := 0; i < 2; i++ {
+		var got testRow
+		require.NoError(t, Unmarshal(r, &got))
+		want := row
+		want.Skipped = ""
+		assert.Equal(t, want, got)
+	}
+
+	// want EOF
+	_, err = r.ReadUint8()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestMarshalNullable(t *testing.T) {
+	type row struct {
+		Count  uint32  `ch:"Count,Nullable(UInt32)"`
+		Value  float64 `ch:"Value,Nullable(Float64)"`
+		Level  *uint8  `ch:"Level,Nullable(UInt8)"`
+		Parent *string `ch:"Parent,Nullable(String)"`
+	}
+	level := uint8(2)
+	tests := []row{
+		{Count: NullUint32, Value: 1},
+		{Count: 1, Value: math.Inf(1), Level: &level},
+	}
+	for _, tt := range tests {
+		wb := &bytes.Buffer{}
+		require.NoError(t, Marshal(NewWriter(wb), tt))
+
+		var got row
+		require.NoError(t, Unmarshal(NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0), &got))
+		assert.Equal(t, tt, got)
+	}
+
+	wb := &bytes.Buffer{}
+	require.NoError(t, Marshal(NewWriter(wb), row{Value: math.NaN()}))
+	var got row
+	require.NoError(t, Unmarshal(NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0), &got))
+	assert.True(t, math.IsNaN(got.Value))
+}
+
+func TestCodecOfErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		wantErr string
+	}{
+		{name: "not struct", v: 1, wantErr: "unsupported type: int, want struct"},
+		{
+			name: "no tags",
+			v: struct {
+				A int
+			}{},
+			wantErr: ": no fields with ch tag",
+		},
+		{
+			name: "invalid tag",
+			v: struct {
+				A int `ch:"A"`
+			}{},
+			wantErr: ".A: invalid ch tag 'A', want Column,Type",
+		},
+		{
+			name: "type mismatch",
+			v: struct {
+				A int `ch:"A,UInt32"`
+			}{},
+			wantErr: ".A: unsupported type: UInt32 for int",
+		},
+		{
+			name: "unknown type",
+			v: struct {
+				A string `ch:"A,Enum8('a' = 1)"`
+			}{},
+			wantErr: ".A: unsupported type: Enum8('a' = 1) for string",
+		},
+		{
+			name: "nullable without sentinel",
+			v: struct {
+				A uint8 `ch:"A,Nullable(UInt8)"`
+			}{},
+			wantErr: ".A: unsupported type: Nullable(UInt8) for uint8",
+		},
+		{
+			name: "decimal32 scale",
+			v: struct {
+				A float64 `ch:"A,Decimal32(10)"`
+			}{},
+			wantErr: ".A: unsupported type: Decimal32(10) for float64",
+		},
+		{
+			name: "decimal64 scale",
+			v: struct {
+				A float64 `ch:"A,Decimal64(-1)"`
+			}{},
+			wantErr: ".A: unsupported type: Decimal64(-1) for float64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CodecOf(tt.v)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMarshalDecimalOverflow(t *testing.T) {
+	row := struct {
+		A float64 `ch:"A,Decimal32(2)"`
+	}{A: 1e8}
+	codec, err := CodecOf(row)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	err = codec.Marshal(NewWriter(&buf), &row)
+	assert.ErrorIs(t, err, ErrDecimalOverflow)
+}
+
+func TestMarshalDecimal64Int(t *testing.T) {
+	type row struct {
+		A int64 `ch:"A,Decimal64(2)"`
+	}
+	in := row{A: 9007199254740993} // 2^53 + 1, not exact in float64
+	codec, err := CodecOf(in)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, codec.Marshal(NewWriter(&buf), &in))
+
+	var out row
+	require.NoError(t, codec.Unmarshal(NewReaderBuffered(bytes.NewReader(buf.Bytes()), 0), &out))
+	assert.Equal(t, in, out)
+
+	in.A = 1e18
+	err = codec.Marshal(NewWriter(&buf), &in)
+	assert.ErrorIs(t, err, ErrDecimalOverflow)
+
+	_, err = CodecOf(struct {
+		A int64 `ch:"A,Decimal32(2)"`
+	}{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestUnmarshalNotPointer(t *testing.T) {
+	err := Unmarshal(NewReaderBuffered(bytes.NewReader(nil), 0), testRow{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
@@ -1,6 +1,9 @@
 package RowBinary
 
-import "time"
+import (
+	"math/big"
+	"time"
+)
 
 const (
 	SIZE_INT8  = 1
@@ -9,6 +12,17 @@ const (
 	SIZE_INT64 = 8
 )
 
+// Tuple(T1, T2, ...) is serialized as consecutive T1, T2, ... values without any prefix,
+// so use readers and writers for each element.
+// LowCardinality(T) is serialized as T.
+
+var pow10 = [10]int64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000, 1000000000}
+
+// decimal128Mod is 2^128, used for Int128 two's complement conversion
+var decimal128Mod = new(big.Int).Lsh(big.NewInt(1), 128)
+
+var bigOne = big.NewInt(1)
+
 func DateUint16(n uint16) time.Time {
 	return time.Unix(int64(n)*86400, 0).UTC()
 }
@@ -2,19 +2,35 @@ package RowBinary
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"time"
 )
 
+// DefaultReaderBufferSize is a NewReader buffer size
+const DefaultReaderBufferSize = 64 * 1024
+
+// maxEmptyReads is a limit of consecutive empty reads without error
+const maxEmptyReads = 100
+
+// Reader is a streaming RowBinary reader.
+// Byte slices, returned by Read*Bytes methods, are valid until the next read.
 type Reader struct {
 	wrapped io.Reader
 
+	base  int64 // stream offset of buf[0]
 	start int
 	end   int
 	buf   []byte
 }
 
+// NewReader create reader with DefaultReaderBufferSize buffer
+func NewReader(rdr io.Reader) *Reader {
+	return NewReaderBuffered(rdr, DefaultReaderBufferSize)
+}
+
 func NewReaderBuffered(rdr io.Reader, bufSize int) *Reader {
 	if bufSize < SIZE_INT64 {
 		bufSize = SIZE_INT64
@@ -25,79 +41,124 @@ func NewReaderBuffered(rdr io.Reader, bufSize int) *Reader {
 	}
 }
 
-func (r *Reader) grow(n int) {
-	if n > r.end-r.start {
-		tmp := r.buf
-		r.buf = make([]byte, n)
-		copy(r.buf, tmp[r.start:r.end])
-		if r.start > 0 {
-			r.end -= r.start
-			r.start = 0
-		}
-	}
+// Offset return stream offset of the next unread byte
+func (r *Reader) Offset() int64 {
+	return r.base + int64(r.start)
 }
 
-func (r *Reader) read(want int) (int, []byte, error) {
-	size := r.end - r.start
-	if size >= want {
-		// all in buffer
-		start := r.start
-		r.start += want
-		return want, r.buf[start:r.start], nil
-	}
-
-	newSize := r.start + want
-	if newSize > cap(r.buf) {
-		// buffer need to grow
-		r.grow(newSize)
-	}
+// Buffered return count of buffered, but not read bytes
+func (r *Reader) Buffered() int {
+	return r.end - r.start
+}
 
-	if n, err := r.wrapped.Read(r.buf[r.end:]); err != nil {
-		return n, nil, err
-	} else {
+// fill read from wrapped reader until at least want bytes are buffered.
+// Buffer grows on demand (not at once to want size, so corrupted length prefix can't cause huge allocation).
+// Return io.EOF if stream ended without any buffered byte and ErrEOF if stream ended with partial data.
+func (r *Reader) fill(want int) error {
+	empty := 0
+	for r.end-r.start < want {
+		if r.end == len(r.buf) {
+			r.makeRoom(want)
+		}
+		n, err := r.wrapped.Read(r.buf[r.end:])
 		r.end += n
-		if r.end-r.start < want {
-			return n, nil, ErrEOF
+		if err != nil {
+			if r.end-r.start >= want {
+				// err is returned again on next read
+				return nil
+			}
+			if err == io.EOF && r.end > r.start {
+				return ErrEOF
+			}
+			return err
+		}
+		if n == 0 {
+			empty++
+			if empty >= maxEmptyReads {
+				return io.ErrNoProgress
+			}
 		} else {
-			start := r.start
-			r.start += want
-			return n, r.buf[start:r.start], nil
+			empty = 0
 		}
 	}
+	return nil
+}
+
+// makeRoom move unread data to buffer start or grow buffer (up to twice, but no more than want)
+func (r *Reader) makeRoom(want int) {
+	buf := r.buf
+	if r.start == 0 || want > len(r.buf) {
+		size := 2 * len(r.buf)
+		if size > want && want > len(r.buf) {
+			size = want
+		}
+		buf = make([]byte, size)
+	}
+	copy(buf, r.buf[r.start:r.end])
+	r.buf = buf
+	r.base += int64(r.start)
+	r.end -= r.start
+	r.start = 0
+}
+
+// read return next want bytes (valid until next read)
+func (r *Reader) read(want int) ([]byte, error) {
+	if err := r.fill(want); err != nil {
+		return nil, err
+	}
+	start := r.start
+	r.start += want
+	return r.buf[start:r.start], nil
 }
 
 func (r *Reader) readUvarint() (uint64, error) {
-	if r.start < r.end {
-		// try to read from buffer
-		if u, n, err := readUvarint(r.buf[r.start:r.end]); err == nil {
+	for {
+		u, n, err := readUvarint(r.buf[r.start:r.end])
+		if err == nil {
 			r.start += n
 			return u, nil
 		} else if err != ErrEOF {
 			return u, err
 		}
+		if r.end-r.start >= binary.MaxVarintLen64 {
+			return 0, ErrUvarintOverflow
+		}
+		if err = r.fill(r.end - r.start + 1); err != nil {
+			return 0, err
+		}
 	}
+}
 
-	newSize := r.start + SIZE_INT64
-	if newSize > cap(r.buf) {
-		// buffer need to grow
-		r.grow(newSize)
+// noEOF convert io.EOF to ErrEOF (for partially read values)
+func noEOF(err error) error {
+	if err == io.EOF {
+		return ErrEOF
 	}
+	return err
+}
+
+// capHint limit preallocated capacity for untrusted length
+func capHint(n int) int {
+	if n > 1024 {
+		return 1024
+	}
+	return n
+}
 
-	if n, err := r.wrapped.Read(r.buf[r.end:]); err != nil {
+// readLen read uvarint length prefix
+func (r *Reader) readLen() (int, error) {
+	u, err := r.readUvarint()
+	if err != nil {
 		return 0, err
-	} else {
-		r.end += n
-		if u, n, err := readUvarint(r.buf[r.start:r.end]); err == nil {
-			r.start += n
-			return u, nil
-		} else {
-			return u, err
-		}
 	}
+	if u > math.MaxInt32 {
+		return 0, ErrLengthOverflow
+	}
+	return int(u), nil
 }
 
 func (r *Reader) ReadUint8() (uint8, error) {
-	if _, buf, err := r.read(SIZE_INT8); err != nil {
+	if buf, err := r.read(SIZE_INT8); err != nil {
 		return 0, err
 	} else {
 		return buf[0], nil
@@ -105,7 +166,7 @@ func (r *Reader) ReadUint8() (uint8, error) {
 }
 
 func (r *Reader) ReadUint16() (uint16, error) {
-	if _, buf, err := r.read(SIZE_INT16); err != nil {
+	if buf, err := r.read(SIZE_INT16); err != nil {
 		return 0, err
 	} else {
 		return binary.LittleEndian.Uint16(buf), nil
@@ -113,7 +174,7 @@ func (r *Reader) ReadUint16() (uint16, error) {
 }
 
 func (r *Reader) ReadUint32() (uint32, error) {
-	if _, buf, err := r.read(SIZE_INT32); err != nil {
+	if buf, err := r.read(SIZE_INT32); err != nil {
 		return 0, err
 	} else {
 		return binary.LittleEndian.Uint32(buf), nil
@@ -121,7 +182,7 @@ func (r *Reader) ReadUint32() (uint32, error) {
 }
 
 func (r *Reader) ReadUint64() (uint64, error) {
-	if _, buf, err := r.read(SIZE_INT64); err != nil {
+	if buf, err := r.read(SIZE_INT64); err != nil {
 		return 0, err
 	} else {
 		return binary.LittleEndian.Uint64(buf), nil
@@ -129,7 +190,7 @@ func (r *Reader) ReadUint64() (uint64, error) {
 }
 
 func (r *Reader) ReadFloat64() (float64, error) {
-	if _, buf, err := r.read(SIZE_INT64); err != nil {
+	if buf, err := r.read(SIZE_INT64); err != nil {
 		return 0, err
 	} else {
 		return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
@@ -137,16 +198,23 @@ func (r *Reader) ReadFloat64() (float64, error) {
 }
 
 func (r *Reader) ReadString() (string, error) {
-	if u, err := r.readUvarint(); err != nil {
+	if buf, err := r.ReadStringBytes(); err != nil {
 		return "", err
 	} else {
-		if u == 0 {
-			return "", nil
-		} else if _, buf, err := r.read(int(u)); err != nil {
-			return "", ErrEOF
-		} else {
-			return string(buf), nil
-		}
+		return string(buf), nil
+	}
+}
+
+// ReadStringBytes read String without copy, returned slice is valid until the next read
+func (r *Reader) ReadStringBytes() ([]byte, error) {
+	if n, err := r.readLen(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return []byte{}, nil
+	} else if buf, err := r.read(n); err != nil {
+		return nil, noEOF(err)
+	} else {
+		return buf, nil
 	}
 }
 
@@ -159,19 +227,277 @@ func (r *Reader) ReadDate() (time.Time, error) {
 }
 
 func (r *Reader) ReadStringList() ([]string, error) {
-	if u, err := r.readUvarint(); err != nil {
+	if n, err := r.readLen(); err != nil {
 		return nil, err
 	} else {
-		if u == 0 {
-			return []string{}, nil
-		}
-		n := int(u)
-		sList := make([]string, n)
+		sList := make([]string, 0, capHint(n))
 		for i := 0; i < n; i++ {
-			if sList[i], err = r.ReadString(); err != nil {
-				return sList, err
+			if s, err := r.ReadString(); err != nil {
+				return sList, noEOF(err)
+			} else {
+				sList = append(sList, s)
 			}
 		}
 		return sList, nil
 	}
 }
+
+// ReadIsNull read Nullable marker, value must be read after it only if marker is false
+func (r *Reader) ReadIsNull() (bool, error) {
+	if v, err := r.ReadUint8(); err != nil {
+		return false, err
+	} else {
+		return v != 0, nil
+	}
+}
+
+func (r *Reader) ReadInt8() (int8, error) {
+	v, err := r.ReadUint8()
+	return int8(v), err
+}
+
+func (r *Reader) ReadInt16() (int16, error) {
+	v, err := r.ReadUint16()
+	return int16(v), err
+}
+
+func (r *Reader) ReadInt32() (int32, error) {
+	v, err := r.ReadUint32()
+	return int32(v), err
+}
+
+func (r *Reader) ReadInt64() (int64, error) {
+	v, err := r.ReadUint64()
+	return int64(v), err
+}
+
+func (r *Reader) ReadFloat32() (float32, error) {
+	if buf, err := r.read(SIZE_INT32); err != nil {
+		return 0, err
+	} else {
+		return math.Float32frombits(binary.LittleEndian.Uint32(buf)), nil
+	}
+}
+
+// ReadNullableUint32 read Nullable(UInt32), NullUint32 is returned for NULL
+func (r *Reader) ReadNullableUint32() (uint32, error) {
+	if isNull, err := r.ReadIsNull(); err != nil {
+		return 0, err
+	} else if isNull {
+		return NullUint32, nil
+	}
+	v, err := r.ReadUint32()
+	return v, noEOF(err)
+}
+
+// ReadNullableFloat64 read Nullable(Float64), NaN is returned for NULL
+func (r *Reader) ReadNullableFloat64() (float64, error) {
+	if isNull, err := r.ReadIsNull(); err != nil {
+		return 0, err
+	} else if isNull {
+		return math.NaN(), nil
+	}
+	v, err := r.ReadFloat64()
+	return v, noEOF(err)
+}
+
+// ReadNullableString read Nullable(String)
+func (r *Reader) ReadNullableString() (string, bool, error) {
+	if isNull, err := r.ReadIsNull(); err != nil {
+		return "", false, err
+	} else if isNull {
+		return "", true, nil
+	}
+	s, err := r.ReadString()
+	return s, false, noEOF(err)
+}
+
+// ReadBytes read String as bytes copy
+func (r *Reader) ReadBytes() ([]byte, error) {
+	if buf, err := r.ReadStringBytes(); err != nil {
+		return nil, err
+	} else {
+		b := make([]byte, len(buf))
+		copy(b, buf)
+		return b, nil
+	}
+}
+
+// ReadLowCardinalityString read LowCardinality(String), in RowBinary it's the same as String
+func (r *Reader) ReadLowCardinalityString() (string, error) {
+	return r.ReadString()
+}
+
+// ReadDateTime read DateTime (UInt32 unix timestamp)
+func (r *Reader) ReadDateTime() (time.Time, error) {
+	if t, err := r.ReadUint32(); err != nil {
+		return time.Unix(0, 0), err
+	} else {
+		return time.Unix(int64(t), 0).UTC(), nil
+	}
+}
+
+// ReadDateTime64 read DateTime64(precision)
+func (r *Reader) ReadDateTime64(precision int) (time.Time, error) {
+	if precision < 0 || precision > 9 {
+		return time.Unix(0, 0), fmt.Errorf("%w: %d", ErrPrecision, precision)
+	}
+	if t, err := r.ReadInt64(); err != nil {
+		return time.Unix(0, 0), err
+	} else {
+		return time.Unix(0, t*pow10[9-precision]).UTC(), nil
+	}
+}
+
+// ReadUUID read UUID (in canonical bytes order)
+func (r *Reader) ReadUUID() ([16]byte, error) {
+	var value [16]byte
+	if buf, err := r.read(16); err != nil {
+		return value, err
+	} else {
+		for i := 0; i < 8; i++ {
+			value[i] = buf[7-i]
+			value[8+i] = buf[15-i]
+		}
+		return value, nil
+	}
+}
+
+// ReadFixedString read FixedString(n), trailing zero bytes are trimmed
+func (r *Reader) ReadFixedString(n int) (string, error) {
+	if n == 0 {
+		return "", nil
+	}
+	if buf, err := r.read(n); err != nil {
+		return "", err
+	} else {
+		end := len(buf)
+		for end > 0 && buf[end-1] == 0 {
+			end--
+		}
+		return string(buf[:end]), nil
+	}
+}
+
+// ReadDecimal32 read Decimal32(scale)
+func (r *Reader) ReadDecimal32(scale int) (float64, error) {
+	if err := checkDecimalScale(scale, Decimal32Precision); err != nil {
+		return 0, err
+	}
+	v, err := r.ReadInt32()
+	return float64(v) / math.Pow10(scale), err
+}
+
+// ReadDecimal64 read Decimal64(scale)
+func (r *Reader) ReadDecimal64(scale int) (float64, error) {
+	v, err := r.ReadDecimal64Raw(scale)
+	return float64(v) / math.Pow10(scale), err
+}
+
+// ReadDecimal64Raw read Decimal64(scale) as scaled value (exact)
+func (r *Reader) ReadDecimal64Raw(scale int) (int64, error) {
+	if err := checkDecimalScale(scale, Decimal64Precision); err != nil {
+		return 0, err
+	}
+	return r.ReadInt64()
+}
+
+// ReadDecimal128 read Decimal128 (Int128, scaled value)
+func (r *Reader) ReadDecimal128() (*big.Int, error) {
+	if buf, err := r.read(16); err != nil {
+		return nil, err
+	} else {
+		var be [16]byte
+		for i := 0; i < 16; i++ {
+			be[i] = buf[15-i]
+		}
+		v := new(big.Int).SetBytes(be[:])
+		if be[0]&0x80 != 0 {
+			v.Sub(v, decimal128Mod)
+		}
+		return v, nil
+	}
+}
+
+// ReadMapStringString read Map(String, String)
+func (r *Reader) ReadMapStringString() (map[string]string, error) {
+	if n, err := r.readLen(); err != nil {
+		return nil, err
+	} else {
+		m := make(map[string]string, capHint(n))
+		for i := 0; i < n; i++ {
+			k, err := r.ReadString()
+			if err != nil {
+				return m, noEOF(err)
+			}
+			if m[k], err = r.ReadString(); err != nil {
+				return m, noEOF(err)
+			}
+		}
+		return m, nil
+	}
+}
+
+func (r *Reader) ReadUint32List() ([]uint32, error) {
+	if n, err := r.readLen(); err != nil {
+		return nil, err
+	} else {
+		list := make([]uint32, 0, capHint(n))
+		for i := 0; i < n; i++ {
+			if v, err := r.ReadUint32(); err != nil {
+				return list, noEOF(err)
+			} else {
+				list = append(list, v)
+			}
+		}
+		return list, nil
+	}
+}
+
+func (r *Reader) ReadNullableUint32List() ([]uint32, error) {
+	if n, err := r.readLen(); err != nil {
+		return nil, err
+	} else {
+		list := make([]uint32, 0, capHint(n))
+		for i := 0; i < n; i++ {
+			if v, err := r.ReadNullableUint32(); err != nil {
+				return list, noEOF(err)
+			} else {
+				list = append(list, v)
+			}
+		}
+		return list, nil
+	}
+}
+
+func (r *Reader) ReadFloat64List() ([]float64, error) {
+	if n, err := r.readLen(); err != nil {
+		return nil, err
+	} else {
+		list := make([]float64, 0, capHint(n))
+		for i := 0; i < n; i++ {
+			if v, err := r.ReadFloat64(); err != nil {
+				return list, noEOF(err)
+			} else {
+				list = append(list, v)
+			}
+		}
+		return list, nil
+	}
+}
+
+func (r *Reader) ReadNullableFloat64List() ([]float64, error) {
+	if n, err := r.readLen(); err != nil {
+		return nil, err
+	} else {
+		list := make([]float64, 0, capHint(n))
+		for i := 0; i < n; i++ {
+			if v, err := r.ReadNullableFloat64(); err != nil {
+				return list, noEOF(err)
+			} else {
+				list = append(list, v)
+			}
+		}
+		return list, nil
+	}
+}
@@ -0,0 +1,189 @@
+package RowBinary
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeStrings(values []string) []byte {
+	e := NewEncoder(0)
+	for _, v := range values {
+		e.WriteString(v)
+	}
+	return e.Bytes()
+}
+
+func TestReaderShortReads(t *testing.T) {
+	values := []string{"", "a", strings.Repeat("b", 100), "c", strings.Repeat("d", 1000)}
+	data := encodeStrings(values)
+
+	readers := map[string]func() io.Reader{
+		"OneByteReader": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(data)) },
+		"HalfReader":    func() io.Reader { return iotest.HalfReader(bytes.NewReader(data)) },
+		"DataErrReader": func() io.Reader { return iotest.DataErrReader(bytes.NewReader(data)) },
+	}
+	for name, newReader := range readers {
+		t.Run(name, func(t *testing.T) {
+			r := NewReaderBuffered(newReader(), 0)
+			for _, want := range values {
+				got, err := r.ReadString()
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+			assert.Equal(t, int64(len(data)), r.Offset())
+
+			// want EOF
+			_, err := r.ReadString()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestReaderPartial(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		read    func(r *Reader) error
+		wantErr error
+	}{
+		{
+			name:    "empty",
+			data:    []byte{},
+			read:    func(r *Reader) error { _, err := r.ReadUint64(); return err },
+			wantErr: io.EOF,
+		},
+		{
+			name:    "uint64",
+			data:    []byte{1, 2, 3},
+			read:    func(r *Reader) error { _, err := r.ReadUint64(); return err },
+			wantErr: ErrEOF,
+		},
+		{
+			name:    "string length only",
+			data:    []byte{3},
+			read:    func(r *Reader) error { _, err := r.ReadString(); return err },
+			wantErr: ErrEOF,
+		},
+		{
+			name:    "string data",
+			data:    []byte{3, 'a'},
+			read:    func(r *Reader) error { _, err := r.ReadString(); return err },
+			wantErr: ErrEOF,
+		},
+		{
+			name:    "uvarint",
+			data:    []byte{0x80, 0x80},
+			read:    func(r *Reader) error { _, err := r.ReadString(); return err },
+			wantErr: ErrEOF,
+		},
+		{
+			name:    "uvarint overflow",
+			data:    bytes.Repeat([]byte{0xff}, 11),
+			read:    func(r *Reader) error { _, err := r.ReadString(); return err },
+			wantErr: ErrUvarintOverflow,
+		},
+		{
+			name:    "length overflow",
+			data:    []byte{0xff, 0xff, 0xff, 0xff, 0x0f},
+			read:    func(r *Reader) error { _, err := r.ReadString(); return err },
+			wantErr: ErrLengthOverflow,
+		},
+		{
+			name:    "list",
+			data:    []byte{2, 1, 'a'},
+			read:    func(r *Reader) error { _, err := r.ReadStringList(); return err },
+			wantErr: ErrEOF,
+		},
+		{
+			name:    "nullable",
+			data:    []byte{0},
+			read:    func(r *Reader) error { _, err := r.ReadNullableUint32(); return err },
+			wantErr: ErrEOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReaderBuffered(iotest.OneByteReader(bytes.NewReader(tt.data)), 0)
+			assert.Equal(t, tt.wantErr, tt.read(r))
+		})
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	readErr := errors.New("read failed")
+	r := NewReaderBuffered(io.MultiReader(bytes.NewReader([]byte{1, 2}), iotest.ErrReader(readErr)), 0)
+	_, err := r.ReadUint32()
+	assert.Equal(t, readErr, err)
+}
+
+func TestReaderStringBytes(t *testing.T) {
+	values := []string{"abc", strings.Repeat("d", 20), ""}
+	r := NewReaderBuffered(bytes.NewReader(encodeStrings(values)), 16)
+	for _, want := range values {
+		got, err := r.ReadStringBytes()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	}
+	_, err := r.ReadStringBytes()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReaderCompact(t *testing.T) {
+	// buffer must not grow for small values
+	e := NewEncoder(0)
+	for i := 0; i < 1000; i++ {
+		e.WriteUint32(uint32(i))
+		e.WriteString("test")
+	}
+	r := NewReaderBuffered(iotest.HalfReader(bytes.NewReader(e.Bytes())), 16)
+	for i := 0; i < 1000; i++ {
+		n, err := r.ReadUint32()
+		require.NoError(t, err)
+		assert.Equal(t, uint32(i), n)
+		s, err := r.ReadStringBytes()
+		require.NoError(t, err)
+		assert.Equal(t, "test", string(s))
+	}
+	assert.Equal(t, 16, len(r.buf))
+	assert.Equal(t, int64(e.Len()), r.Offset())
+	assert.Equal(t, 0, r.Buffered())
+}
+
+func BenchmarkReaderStringBytes(b *testing.B) {
+	paths, _ := benchmarkMetrics()
+	data := encodeStrings(paths)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := NewReaderBuffered(bytes.NewReader(data), 4096)
+		for {
+			if _, err := r.ReadStringBytes(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkReaderString(b *testing.B) {
+	paths, _ := benchmarkMetrics()
+	data := encodeStrings(paths)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		r := NewReaderBuffered(bytes.NewReader(data), 4096)
+		for {
+			if _, err := r.ReadString(); err != nil {
+				break
+			}
+		}
+	}
+}
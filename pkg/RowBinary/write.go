@@ -2,8 +2,11 @@ package RowBinary
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"sort"
 	"time"
 
 	"github.com/msaf1980/go-stringutils"
@@ -21,7 +24,7 @@ func Date(year int, month time.Month, day int) uint16 {
 
 type Writer struct {
 	wrapped io.Writer
-	buffer  [SIZE_INT64]byte
+	buffer  [binary.MaxVarintLen64]byte
 }
 
 func NewWriter(w io.Writer) *Writer {
@@ -186,3 +189,187 @@ func (w *Writer) NullableFloat64List(value []float64) error {
 
 	return nil
 }
+
+// WriteNull write Nullable marker, value must be written after it only if isNull is false
+func (w *Writer) WriteNull(isNull bool) error {
+	if isNull {
+		return w.WriteUint8(1)
+	}
+	return w.WriteUint8(0)
+}
+
+func (w *Writer) WriteInt8(value int8) error {
+	return w.WriteUint8(uint8(value))
+}
+
+func (w *Writer) WriteInt16(value int16) error {
+	return w.WriteUint16(uint16(value))
+}
+
+func (w *Writer) WriteInt32(value int32) error {
+	return w.WriteUint32(uint32(value))
+}
+
+func (w *Writer) WriteInt64(value int64) error {
+	return w.WriteUint64(uint64(value))
+}
+
+func (w *Writer) WriteFloat32(value float32) error {
+	return w.WriteUint32(math.Float32bits(value))
+}
+
+// WriteDateTime write DateTime (UInt32 unix timestamp)
+func (w *Writer) WriteDateTime(value time.Time) error {
+	return w.WriteUint32(uint32(value.Unix()))
+}
+
+// WriteDateTime64 write DateTime64(precision) (Int64 ticks since epoch, precision is in 0..9 range)
+func (w *Writer) WriteDateTime64(value time.Time, precision int) error {
+	if precision < 0 || precision > 9 {
+		return fmt.Errorf("%w: %d", ErrPrecision, precision)
+	}
+	return w.WriteInt64(value.UnixNano() / pow10[9-precision])
+}
+
+// WriteUUID write UUID (in canonical bytes order), stored as two little-endian UInt64 (high and low parts)
+func (w *Writer) WriteUUID(value [16]byte) error {
+	var buf [16]byte
+	for i := 0; i < 8; i++ {
+		buf[i] = value[7-i]
+		buf[8+i] = value[15-i]
+	}
+	_, err := w.wrapped.Write(buf[:])
+	return err
+}
+
+// WriteFixedString write FixedString(n), value is padded with zero bytes
+func (w *Writer) WriteFixedString(value string, n int) error {
+	if len(value) > n {
+		return fmt.Errorf("%w: length %d, want %d or less", ErrFixedStringLength, len(value), n)
+	}
+	if _, err := w.wrapped.Write(stringutils.UnsafeStringBytes(&value)); err != nil {
+		return err
+	}
+	for i := len(value); i < n; i++ {
+		if err := w.WriteUint8(0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decimal precisions (max digits)
+const (
+	Decimal32Precision = 9
+	Decimal64Precision = 18
+)
+
+// decimal64Limit is a Decimal64 scaled value limit (10^18)
+const decimal64Limit int64 = 1000000000000000000
+
+// checkDecimalScale check decimal scale for precision
+func checkDecimalScale(scale, precision int) error {
+	if scale < 0 || scale > precision {
+		return fmt.Errorf("%w: scale %d out of range [0, %d]", ErrDecimalOverflow, scale, precision)
+	}
+	return nil
+}
+
+// decimalScaled return value, scaled by 10^scale and rounded, checked for scale and precision
+func decimalScaled(value float64, scale, precision int) (float64, error) {
+	if err := checkDecimalScale(scale, precision); err != nil {
+		return 0, err
+	}
+	v := math.Round(value * math.Pow10(scale))
+	if math.IsNaN(v) || math.Abs(v) >= math.Pow10(precision) {
+		return 0, fmt.Errorf("%w: %v with scale %d exceed %d digits", ErrDecimalOverflow, value, scale, precision)
+	}
+	return v, nil
+}
+
+// WriteDecimal32 write Decimal32(scale) (Int32, scaled by 10^scale)
+func (w *Writer) WriteDecimal32(value float64, scale int) error {
+	v, err := decimalScaled(value, scale, Decimal32Precision)
+	if err != nil {
+		return err
+	}
+	return w.WriteInt32(int32(v))
+}
+
+// WriteDecimal64 write Decimal64(scale) (Int64, scaled by 10^scale)
+func (w *Writer) WriteDecimal64(value float64, scale int) error {
+	v, err := decimalScaled(value, scale, Decimal64Precision)
+	if err != nil {
+		return err
+	}
+	return w.WriteInt64(int64(v))
+}
+
+// WriteDecimal64Raw write Decimal64(scale) from already scaled value (exact, float64 lose precision above 2^53)
+func (w *Writer) WriteDecimal64Raw(value int64, scale int) error {
+	if err := checkDecimalScale(scale, Decimal64Precision); err != nil {
+		return err
+	}
+	if value <= -decimal64Limit || value >= decimal64Limit {
+		return fmt.Errorf("%w: %d exceed %d digits", ErrDecimalOverflow, value, Decimal64Precision)
+	}
+	return w.WriteInt64(value)
+}
+
+// WriteDecimal128 write Decimal128 (Int128, already scaled value)
+func (w *Writer) WriteDecimal128(value *big.Int) error {
+	var buf [16]byte
+	v := value
+	if value.Sign() >= 0 {
+		if value.BitLen() > 127 {
+			return ErrDecimalOverflow
+		}
+	} else {
+		if new(big.Int).Add(value, bigOne).BitLen() > 127 {
+			return ErrDecimalOverflow
+		}
+		// two's complement
+		v = new(big.Int).Add(value, decimal128Mod)
+	}
+	b := v.Bytes()
+	for i := 0; i < len(b); i++ {
+		buf[i] = b[len(b)-1-i]
+	}
+	_, err := w.wrapped.Write(buf[:])
+	return err
+}
+
+// WriteLowCardinalityString write LowCardinality(String), in RowBinary it's the same as String
+func (w *Writer) WriteLowCardinalityString(value string) error {
+	return w.WriteString(value)
+}
+
+// WriteNullableString write Nullable(String)
+func (w *Writer) WriteNullableString(value string, isNull bool) error {
+	if err := w.WriteNull(isNull); err != nil || isNull {
+		return err
+	}
+	return w.WriteString(value)
+}
+
+// WriteMapStringString write Map(String, String) (elements count and key-value pairs, sorted by key).
+// Other Map(K, V) types can be written with WriteUvarint and values writers.
+func (w *Writer) WriteMapStringString(value map[string]string) error {
+	if _, err := w.WriteUvarint(uint64(len(value))); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(value))
+	for k := range value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := w.WriteString(k); err != nil {
+			return err
+		}
+		if err := w.WriteString(value[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"io"
 	"math"
+	"math/big"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWriteUint8(t *testing.T) {
@@ -199,3 +201,481 @@ func TestWriteStringList(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteInts(t *testing.T) {
+	tests := []int64{0, -1, 1, math.MinInt8, math.MaxInt8, math.MinInt16, math.MaxInt16, math.MinInt32, math.MaxInt32, math.MinInt64, math.MaxInt64}
+	for _, tt := range tests {
+		t.Run(strconv.FormatInt(tt, 10), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			assert.NoError(t, w.WriteInt8(int8(tt)))
+			assert.NoError(t, w.WriteInt16(int16(tt)))
+			assert.NoError(t, w.WriteInt32(int32(tt)))
+			assert.NoError(t, w.WriteInt64(tt))
+			assert.Equal(t, 1+2+4+8, wb.Len())
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			i8, err := r.ReadInt8()
+			assert.NoError(t, err)
+			assert.Equal(t, int8(tt), i8)
+			i16, err := r.ReadInt16()
+			assert.NoError(t, err)
+			assert.Equal(t, int16(tt), i16)
+			i32, err := r.ReadInt32()
+			assert.NoError(t, err)
+			assert.Equal(t, int32(tt), i32)
+			i64, err := r.ReadInt64()
+			assert.NoError(t, err)
+			assert.Equal(t, tt, i64)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteFloat32(t *testing.T) {
+	tests := []float32{0, -1.5, 126.25, math.MaxFloat32, math.SmallestNonzeroFloat32}
+	for _, tt := range tests {
+		t.Run(strconv.FormatFloat(float64(tt), 'g', -1, 32), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			assert.NoError(t, w.WriteFloat32(tt))
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadFloat32()
+			assert.NoError(t, err)
+			assert.Equal(t, tt, got)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteNullable(t *testing.T) {
+	wb := &bytes.Buffer{}
+	w := NewWriter(wb)
+	assert.NoError(t, w.WriteNullableUint32(NullUint32))
+	assert.NoError(t, w.WriteNullableUint32(12))
+	assert.NoError(t, w.WriteNullableFloat64(math.NaN()))
+	assert.NoError(t, w.WriteNullableFloat64(1.5))
+	assert.NoError(t, w.WriteNullableString("", true))
+	assert.NoError(t, w.WriteNullableString("test", false))
+
+	r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+	u, err := r.ReadNullableUint32()
+	assert.NoError(t, err)
+	assert.Equal(t, NullUint32, u)
+	u, err = r.ReadNullableUint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(12), u)
+	f, err := r.ReadNullableFloat64()
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(f))
+	f, err = r.ReadNullableFloat64()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+	s, isNull, err := r.ReadNullableString()
+	assert.NoError(t, err)
+	assert.True(t, isNull)
+	assert.Equal(t, "", s)
+	s, isNull, err = r.ReadNullableString()
+	assert.NoError(t, err)
+	assert.False(t, isNull)
+	assert.Equal(t, "test", s)
+
+	// want EOF
+	_, err = r.ReadUint8()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWriteDateTime(t *testing.T) {
+	tests := []time.Time{
+		time.Unix(0, 0).UTC(),
+		time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC),
+		time.Date(2106, 2, 7, 6, 28, 15, 0, time.UTC),
+	}
+	for _, tt := range tests {
+		t.Run(tt.Format(time.RFC3339), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			assert.NoError(t, w.WriteDateTime(tt))
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadDateTime()
+			assert.NoError(t, err)
+			assert.Equal(t, tt, got)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteDateTime64(t *testing.T) {
+	ts := time.Date(2021, 2, 3, 4, 5, 6, 123456789, time.UTC)
+	tests := []struct {
+		precision int
+		want      time.Time
+		wantErr   bool
+	}{
+		{precision: 0, want: ts.Truncate(time.Second)},
+		{precision: 3, want: ts.Truncate(time.Millisecond)},
+		{precision: 6, want: ts.Truncate(time.Microsecond)},
+		{precision: 9, want: ts},
+		{precision: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.precision), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			err := w.WriteDateTime64(ts, tt.precision)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrPrecision)
+				return
+			}
+			assert.NoError(t, err)
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadDateTime64(tt.precision)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteUUID(t *testing.T) {
+	// 61f0c404-5cb3-11e7-907b-a6006ad3dba0
+	uuid := [16]byte{0x61, 0xf0, 0xc4, 0x04, 0x5c, 0xb3, 0x11, 0xe7, 0x90, 0x7b, 0xa6, 0x00, 0x6a, 0xd3, 0xdb, 0xa0}
+	want := []byte{0xe7, 0x11, 0xb3, 0x5c, 0x04, 0xc4, 0xf0, 0x61, 0xa0, 0xdb, 0xd3, 0x6a, 0x00, 0xa6, 0x7b, 0x90}
+
+	wb := &bytes.Buffer{}
+	w := NewWriter(wb)
+	assert.NoError(t, w.WriteUUID(uuid))
+	assert.Equal(t, want, wb.Bytes())
+
+	r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+	got, err := r.ReadUUID()
+	assert.NoError(t, err)
+	assert.Equal(t, uuid, got)
+
+	// want EOF
+	_, err = r.ReadUint8()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWriteFixedString(t *testing.T) {
+	tests := []struct {
+		value   string
+		n       int
+		wantErr bool
+	}{
+		{value: "", n: 0},
+		{value: "", n: 4},
+		{value: "ab", n: 4},
+		{value: "abcd", n: 4},
+		{value: "abcde", n: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+"/"+strconv.Itoa(tt.n), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			err := w.WriteFixedString(tt.value, tt.n)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrFixedStringLength)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.n, wb.Len())
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadFixedString(tt.n)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteDecimal(t *testing.T) {
+	tests := []struct {
+		value float64
+		scale int
+	}{
+		{value: 0, scale: 0},
+		{value: 12.34, scale: 2},
+		{value: -12.345, scale: 3},
+		{value: 99999.9999, scale: 4},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatFloat(tt.value, 'f', -1, 64), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			assert.NoError(t, w.WriteDecimal32(tt.value, tt.scale))
+			assert.NoError(t, w.WriteDecimal64(tt.value, tt.scale))
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadDecimal32(tt.scale)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.value, got, 1e-9)
+			got, err = r.ReadDecimal64(tt.scale)
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.value, got, 1e-9)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteDecimalOverflow(t *testing.T) {
+	tests := []struct {
+		value     float64
+		scale     int
+		wantErr32 bool
+		wantErr64 bool
+	}{
+		{value: 999999999, scale: 0},
+		{value: -999999999, scale: 0},
+		{value: 1e9, scale: 0, wantErr32: true},
+		{value: -1e9, scale: 0, wantErr32: true},
+		{value: 9999999.99, scale: 2},
+		{value: 1e7, scale: 2, wantErr32: true},
+		{value: 0.5, scale: 9},
+		{value: 1, scale: 9, wantErr32: true},
+		{value: 3e9, scale: 0, wantErr32: true},                                 // wrap int32
+		{value: 999999999999999999, scale: 0, wantErr32: true, wantErr64: true}, // rounded to 1e18 in float64
+		{value: 999999999.99, scale: 2, wantErr32: true},
+		{value: 1e17, scale: 1, wantErr32: true, wantErr64: true},
+		{value: 1e19, scale: 0, wantErr32: true, wantErr64: true}, // wrap int64
+		{value: 0.1, scale: 18, wantErr32: true},
+		{value: 0.1, scale: 19, wantErr32: true, wantErr64: true},
+		{value: 1, scale: -1, wantErr32: true, wantErr64: true},
+		{value: math.Inf(1), scale: 2, wantErr32: true, wantErr64: true},
+		{value: math.NaN(), scale: 2, wantErr32: true, wantErr64: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatFloat(tt.value, 'g', -1, 64)+"/"+strconv.Itoa(tt.scale), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			if err := w.WriteDecimal32(tt.value, tt.scale); tt.wantErr32 {
+				assert.ErrorIs(t, err, ErrDecimalOverflow)
+			} else {
+				require.NoError(t, err)
+				got, err := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0).ReadDecimal32(tt.scale)
+				require.NoError(t, err)
+				assert.InDelta(t, tt.value, got, math.Pow10(-tt.scale))
+			}
+			wb.Reset()
+			if err := w.WriteDecimal64(tt.value, tt.scale); tt.wantErr64 {
+				assert.ErrorIs(t, err, ErrDecimalOverflow)
+			} else {
+				require.NoError(t, err)
+				got, err := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0).ReadDecimal64(tt.scale)
+				require.NoError(t, err)
+				assert.InDelta(t, tt.value, got, math.Pow10(-tt.scale))
+			}
+		})
+	}
+}
+
+func TestWriteDecimal64Raw(t *testing.T) {
+	tests := []struct {
+		value   int64
+		scale   int
+		wantErr bool
+	}{
+		{value: 0, scale: 0},
+		{value: 9007199254740993, scale: 2}, // 2^53 + 1, not exact in float64
+		{value: 999999999999999999, scale: 18},
+		{value: -999999999999999999, scale: 0},
+		{value: 1000000000000000000, scale: 0, wantErr: true},
+		{value: -1000000000000000000, scale: 0, wantErr: true},
+		{value: math.MaxInt64, scale: 0, wantErr: true},
+		{value: 1, scale: 19, wantErr: true},
+		{value: 1, scale: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.FormatInt(tt.value, 10)+"/"+strconv.Itoa(tt.scale), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			err := w.WriteDecimal64Raw(tt.value, tt.scale)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrDecimalOverflow)
+				assert.Zero(t, wb.Len())
+				return
+			}
+			require.NoError(t, err)
+			got, err := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0).ReadDecimal64Raw(tt.scale)
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+}
+
+func TestReadDecimalScale(t *testing.T) {
+	data := make([]byte, 8)
+	for _, scale := range []int{-1, 19} {
+		r := NewReaderBuffered(bytes.NewReader(data), 0)
+		_, err := r.ReadDecimal64(scale)
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+		_, err = r.ReadDecimal64Raw(scale)
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+	}
+	for _, scale := range []int{-1, 10} {
+		r := NewReaderBuffered(bytes.NewReader(data), 0)
+		_, err := r.ReadDecimal32(scale)
+		assert.ErrorIs(t, err, ErrDecimalOverflow)
+	}
+}
+
+func TestWriteDecimal128(t *testing.T) {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	min := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	tests := []struct {
+		value   *big.Int
+		wantErr bool
+	}{
+		{value: big.NewInt(0)},
+		{value: big.NewInt(-1)},
+		{value: big.NewInt(1234567890123)},
+		{value: max},
+		{value: min},
+		{value: new(big.Int).Add(max, big.NewInt(1)), wantErr: true},
+		{value: new(big.Int).Sub(min, big.NewInt(1)), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value.String(), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			err := w.WriteDecimal128(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrDecimalOverflow)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, 16, wb.Len())
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadDecimal128()
+			assert.NoError(t, err)
+			assert.Equal(t, 0, tt.value.Cmp(got), got.String())
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteMapStringString(t *testing.T) {
+	tests := []map[string]string{
+		{},
+		{"a": "1"},
+		{"b": "2", "a": "1", "c": ""},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			wb := &bytes.Buffer{}
+			w := NewWriter(wb)
+			assert.NoError(t, w.WriteMapStringString(tt))
+
+			r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+			got, err := r.ReadMapStringString()
+			assert.NoError(t, err)
+			assert.Equal(t, tt, got)
+
+			// want EOF
+			_, err = r.ReadUint8()
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestWriteTuple(t *testing.T) {
+	// Array(Tuple(LowCardinality(String), UInt32))
+	keys := []string{"a", "bb", ""}
+	values := []uint32{1, 2, math.MaxUint32}
+
+	wb := &bytes.Buffer{}
+	w := NewWriter(wb)
+	_, err := w.WriteUvarint(uint64(len(keys)))
+	assert.NoError(t, err)
+	for i := range keys {
+		assert.NoError(t, w.WriteLowCardinalityString(keys[i]))
+		assert.NoError(t, w.WriteUint32(values[i]))
+	}
+
+	r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+	n, err := r.ReadUint8()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(len(keys)), n)
+	for i := range keys {
+		k, err := r.ReadLowCardinalityString()
+		assert.NoError(t, err)
+		assert.Equal(t, keys[i], k)
+		v, err := r.ReadUint32()
+		assert.NoError(t, err)
+		assert.Equal(t, values[i], v)
+	}
+
+	// want EOF
+	_, err = r.ReadUint8()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestWriteLists(t *testing.T) {
+	u32 := []uint32{0, 1, math.MaxUint32 - 1}
+	nu32 := []uint32{0, NullUint32, 12}
+	f64 := []float64{0, -1.5, math.MaxFloat64}
+	nf64 := []float64{1.5, math.NaN(), 0}
+
+	wb := &bytes.Buffer{}
+	w := NewWriter(wb)
+	assert.NoError(t, w.Uint32List(u32))
+	assert.NoError(t, w.NullableUint32List(nu32))
+	assert.NoError(t, w.Float64List(f64))
+	assert.NoError(t, w.NullableFloat64List(nf64))
+	assert.NoError(t, w.Uint32List([]uint32{}))
+	assert.NoError(t, w.WriteString("bytes"))
+
+	r := NewReaderBuffered(bytes.NewReader(wb.Bytes()), 0)
+	gotU32, err := r.ReadUint32List()
+	assert.NoError(t, err)
+	assert.Equal(t, u32, gotU32)
+	gotU32, err = r.ReadNullableUint32List()
+	assert.NoError(t, err)
+	assert.Equal(t, nu32, gotU32)
+	gotF64, err := r.ReadFloat64List()
+	assert.NoError(t, err)
+	assert.Equal(t, f64, gotF64)
+	gotF64, err = r.ReadNullableFloat64List()
+	assert.NoError(t, err)
+	if assert.Equal(t, len(nf64), len(gotF64)) {
+		assert.Equal(t, nf64[0], gotF64[0])
+		assert.True(t, math.IsNaN(gotF64[1]))
+		assert.Equal(t, nf64[2], gotF64[2])
+	}
+	gotU32, err = r.ReadUint32List()
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{}, gotU32)
+	b, err := r.ReadBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bytes"), b)
+
+	// want EOF
+	_, err = r.ReadUint8()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,211 @@
+package analyze
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+)
+
+// KeyStat is a tag key cardinality
+type KeyStat struct {
+	Key    string `json:"key"`
+	Values int    `json:"values"` // distinct values
+	Series uint64 `json:"series"` // unique metrics with key
+}
+
+// PathStat is a metric path with measured value (path length or tags count)
+type PathStat struct {
+	Path  string `json:"path"`
+	Value int    `json:"value"`
+}
+
+// Report is a metrics analysis report
+type Report struct {
+	Metrics      uint64     `json:"metrics"`             // tagged metrics read
+	Unique       uint64     `json:"unique"`              // unique tagged metrics (by path)
+	Rows         uint64     `json:"rows"`                // tagged table rows (one per emitted tag) for all read metrics
+	UniqueRows   uint64     `json:"unique_rows"`         // tagged table rows for unique metrics
+	Tag1Keys     string     `json:"tag1_keys,omitempty"` // Tag1 policy keys for rows count (empty for all tags)
+	Plain        uint64     `json:"plain"`               // plain metrics (not analyzed)
+	Invalid      uint64     `json:"invalid"`             // invalid metrics
+	Filtered     uint64     `json:"filtered"`            // metrics, filtered before analysis (not loaded)
+	Rejected     uint64     `json:"rejected"`            // metrics, rejected by limits (not loaded)
+	Keys         int        `json:"keys"`                // distinct tag keys
+	TopKeys      []KeyStat  `json:"top_keys"`            // tag keys with max distinct values
+	LongestPaths []PathStat `json:"longest_paths"`
+	MostTags     []PathStat `json:"most_tags"`
+}
+
+// topN keep n max values (sorted by value desc, equal values in add order)
+type topN struct {
+	n     int
+	items []PathStat
+}
+
+func (t *topN) add(path string, value int) {
+	if len(t.items) == t.n && value <= t.items[len(t.items)-1].Value {
+		return
+	}
+	i := sort.Search(len(t.items), func(i int) bool { return t.items[i].Value < value })
+	if len(t.items) < t.n {
+		t.items = append(t.items, PathStat{})
+	}
+	copy(t.items[i+1:], t.items[i:])
+	t.items[i] = PathStat{Path: path, Value: value}
+}
+
+type keyStat struct {
+	values map[string]struct{}
+	series uint64
+}
+
+// Analyzer collect tagged metrics statistic, metrics are parsed like in drivers
+type Analyzer struct {
+	parse tags.ParseFunc
+	tag1  *driver.Tag1Policy
+	top   int
+
+	paths map[string]struct{}
+	keys  map[string]*keyStat
+
+	longest  topN
+	mostTags topN
+
+	report Report
+}
+
+// New create analyzer with metrics parser (tags.TagsParse if nil) and top lists size
+func New(parse tags.ParseFunc, top int) *Analyzer {
+	if parse == nil {
+		parse = tags.TagsParse
+	}
+	if top < 1 {
+		top = 1
+	}
+	return &Analyzer{
+		parse:    parse,
+		top:      top,
+		paths:    make(map[string]struct{}),
+		keys:     make(map[string]*keyStat),
+		longest:  topN{n: top},
+		mostTags: topN{n: top},
+	}
+}
+
+// SetTag1Policy set Tag1 rows policy (like --tag1-keys for load), nil for all tags
+func (a *Analyzer) SetTag1Policy(p *driver.Tag1Policy) {
+	a.tag1 = p
+	a.report.Tag1Keys = p.String()
+}
+
+// Add analyze metric, error returned for invalid tagged metric
+func (a *Analyzer) Add(metric string) error {
+	if strings.IndexByte(metric, ';') == -1 {
+		a.report.Plain++
+		return nil
+	}
+	path, list, err := a.parse(metric)
+	if err != nil {
+		a.report.Invalid++
+		return err
+	}
+	a.report.Metrics++
+	rows := uint64(a.tag1.Count(list))
+	a.report.Rows += rows
+	if _, ok := a.paths[path]; ok {
+		return nil
+	}
+	a.paths[path] = struct{}{}
+	a.report.Unique++
+	a.report.UniqueRows += rows
+
+	for _, tag := range list {
+		key, value, _ := strings.Cut(tag, "=")
+		ks, ok := a.keys[key]
+		if !ok {
+			ks = &keyStat{values: make(map[string]struct{})}
+			a.keys[key] = ks
+		}
+		ks.values[value] = struct{}{}
+		ks.series++
+	}
+	a.longest.add(path, len(path))
+	a.mostTags.add(path, len(list))
+	return nil
+}
+
+// AddInvalid count metric, found invalid before analysis (like by metrics pipeline)
+func (a *Analyzer) AddInvalid() {
+	a.report.Invalid++
+}
+
+// AddFiltered count metric, filtered before analysis
+func (a *Analyzer) AddFiltered() {
+	a.report.Filtered++
+}
+
+// AddRejected count metric, rejected by limits
+func (a *Analyzer) AddRejected() {
+	a.report.Rejected++
+}
+
+// Report return analysis report
+func (a *Analyzer) Report() Report {
+	r := a.report
+	r.Keys = len(a.keys)
+	r.TopKeys = make([]KeyStat, 0, len(a.keys))
+	for key, ks := range a.keys {
+		r.TopKeys = append(r.TopKeys, KeyStat{Key: key, Values: len(ks.values), Series: ks.series})
+	}
+	sort.Slice(r.TopKeys, func(i, j int) bool {
+		if r.TopKeys[i].Values == r.TopKeys[j].Values {
+			return r.TopKeys[i].Key < r.TopKeys[j].Key
+		}
+		return r.TopKeys[i].Values > r.TopKeys[j].Values
+	})
+	if len(r.TopKeys) > a.top {
+		r.TopKeys = r.TopKeys[:a.top]
+	}
+	r.LongestPaths = append([]PathStat{}, a.longest.items...)
+	r.MostTags = append([]PathStat{}, a.mostTags.items...)
+	return r
+}
+
+// WriteText write report in human-readable format
+func (r *Report) WriteText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "tagged metrics: %d (unique %d, invalid %d), plain metrics: %d\n", r.Metrics, r.Unique, r.Invalid, r.Plain)
+	if r.Filtered > 0 || r.Rejected > 0 {
+		fmt.Fprintf(&sb, "dropped metrics: filtered %d, rejected by limits %d\n", r.Filtered, r.Rejected)
+	}
+	if r.Tag1Keys == "" {
+		fmt.Fprintf(&sb, "tagged rows: %d (for unique metrics %d)\n", r.Rows, r.UniqueRows)
+	} else {
+		fmt.Fprintf(&sb, "tagged rows: %d (for unique metrics %d), Tag1 keys: %s\n", r.Rows, r.UniqueRows, r.Tag1Keys)
+	}
+	fmt.Fprintf(&sb, "tag keys: %d\n", r.Keys)
+	if len(r.TopKeys) > 0 {
+		sb.WriteString("\ntop tag keys by cardinality (values, series):\n")
+		for _, k := range r.TopKeys {
+			fmt.Fprintf(&sb, "  %-32s %10d %10d\n", k.Key, k.Values, k.Series)
+		}
+	}
+	if len(r.LongestPaths) > 0 {
+		sb.WriteString("\nlongest paths (length):\n")
+		for _, p := range r.LongestPaths {
+			fmt.Fprintf(&sb, "  %6d %s\n", p.Value, p.Path)
+		}
+	}
+	if len(r.MostTags) > 0 {
+		sb.WriteString("\nmetrics with most tags (tags):\n")
+		for _, p := range r.MostTags {
+			fmt.Fprintf(&sb, "  %6d %s\n", p.Value, p.Path)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,123 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzer(t *testing.T) {
+	a := New(nil, 2)
+	for _, metric := range []string{
+		"cpu;env=prod;host=h1",
+		"cpu;env=prod;host=h2",
+		"cpu;env=prod;host=h2", // duplicate
+		"cpu;env=test;host=h3;dc=a",
+		"mem_used;host=h1",
+		"plain.metric",
+	} {
+		require.NoError(t, a.Add(metric))
+	}
+	assert.Error(t, a.Add("cpu;env"))
+	a.AddInvalid()
+	a.AddFiltered()
+	a.AddRejected()
+	a.AddRejected()
+
+	r := a.Report()
+	assert.Equal(t, []KeyStat{
+		{Key: "host", Values: 3, Series: 4},
+		{Key: "__name__", Values: 2, Series: 4},
+	}, r.TopKeys)
+	assert.Equal(t, uint64(5), r.Metrics)
+	assert.Equal(t, uint64(4), r.Unique)
+	assert.Equal(t, uint64(15), r.Rows)
+	assert.Equal(t, uint64(12), r.UniqueRows)
+	assert.Equal(t, uint64(1), r.Plain)
+	assert.Equal(t, uint64(2), r.Invalid)
+	assert.Equal(t, uint64(1), r.Filtered)
+	assert.Equal(t, uint64(2), r.Rejected)
+	assert.Equal(t, 4, r.Keys)
+	assert.Equal(t, []PathStat{
+		{Path: "cpu?dc=a&env=test&host=h3", Value: 25},
+		{Path: "cpu?env=prod&host=h1", Value: 20},
+	}, r.LongestPaths)
+	assert.Equal(t, []PathStat{
+		{Path: "cpu?dc=a&env=test&host=h3", Value: 4},
+		{Path: "cpu?env=prod&host=h1", Value: 3},
+	}, r.MostTags)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteText(&buf))
+	assert.Contains(t, buf.String(), "tagged metrics: 5 (unique 4, invalid 2), plain metrics: 1\n")
+	assert.Contains(t, buf.String(), "\ndropped metrics: filtered 1, rejected by limits 2\n")
+	assert.Contains(t, buf.String(), "\n       4 cpu?dc=a&env=test&host=h3\n")
+
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, r, decoded)
+}
+
+func TestAnalyzerTag1Policy(t *testing.T) {
+	metrics := []string{
+		"cpu;env=prod;host=h1",
+		"cpu;env=prod;host=h2",
+		"cpu;env=prod;host=h2", // duplicate
+		"cpu;env=test;host=h3;dc=a",
+		"mem_used;host=h1",
+	}
+	tests := []struct {
+		keys           []string
+		wantRows       uint64
+		wantUniqueRows uint64
+		wantTag1Keys   string
+		wantText       string
+	}{
+		{
+			keys: nil, wantRows: 15, wantUniqueRows: 12,
+			wantText: "tagged rows: 15 (for unique metrics 12)\n",
+		},
+		{
+			keys: []string{"env"}, wantRows: 9, wantUniqueRows: 7, wantTag1Keys: "__name__,env",
+			wantText: "tagged rows: 9 (for unique metrics 7), Tag1 keys: __name__,env\n",
+		},
+		{
+			keys: []string{"__name__"}, wantRows: 5, wantUniqueRows: 4, wantTag1Keys: "__name__",
+			wantText: "tagged rows: 5 (for unique metrics 4), Tag1 keys: __name__\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.keys, ","), func(t *testing.T) {
+			a := New(nil, 2)
+			a.SetTag1Policy(driver.NewTag1Policy(tt.keys))
+			for _, metric := range metrics {
+				require.NoError(t, a.Add(metric))
+			}
+			r := a.Report()
+			assert.Equal(t, tt.wantRows, r.Rows)
+			assert.Equal(t, tt.wantUniqueRows, r.UniqueRows)
+			assert.Equal(t, tt.wantTag1Keys, r.Tag1Keys)
+			// key statistic is not changed by policy
+			assert.Equal(t, 4, r.Keys)
+
+			var buf bytes.Buffer
+			require.NoError(t, r.WriteText(&buf))
+			assert.Contains(t, buf.String(), tt.wantText)
+		})
+	}
+}
+
+func TestTopN(t *testing.T) {
+	top := topN{n: 3}
+	for i, v := range []int{1, 5, 3, 5, 2, 7, 1} {
+		top.add(string(rune('a'+i)), v)
+	}
+	assert.Equal(t, []PathStat{{"f", 7}, {"b", 5}, {"d", 5}}, top.items)
+}
@@ -0,0 +1,174 @@
+package compress
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/cityhash102"
+	"github.com/pierrec/lz4/v4"
+)
+
+// ClickHouse compressed block methods
+const (
+	methodNone byte = 0x02
+	methodLZ4  byte = 0x82
+)
+
+const (
+	checksumSize = 16        // CityHash128 checksum
+	headerSize   = 1 + 4 + 4 // method + compressed size (with header) + uncompressed size
+	maxBlockSize = 1 << 20   // like ClickHouse max_compress_block_size
+
+	maxReadBlockSize = 1 << 30 // protect from corrupted headers
+)
+
+var (
+	ErrChecksum    = errors.New("compressed block checksum mismatch")
+	ErrBlockHeader = errors.New("invalid compressed block header")
+)
+
+// BlockWriter write ClickHouse native compressed blocks:
+// checksum (16 bytes), method (1 byte), compressed size (4 bytes), uncompressed size (4 bytes), data.
+type BlockWriter struct {
+	w          io.Writer
+	data       []byte
+	zdata      []byte
+	compressor lz4.Compressor
+}
+
+func NewBlockWriter(w io.Writer) *BlockWriter {
+	return &BlockWriter{
+		w:     w,
+		data:  make([]byte, 0, maxBlockSize),
+		zdata: make([]byte, checksumSize+headerSize+lz4.CompressBlockBound(maxBlockSize)),
+	}
+}
+
+func (w *BlockWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		m := copy(w.data[len(w.data):cap(w.data)], p)
+		w.data = w.data[:len(w.data)+m]
+		p = p[m:]
+		n += m
+		if len(w.data) == cap(w.data) {
+			if err := w.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush write pending data as compressed block
+func (w *BlockWriter) Flush() error {
+	if len(w.data) == 0 {
+		return nil
+	}
+	method := methodLZ4
+	n, err := w.compressor.CompressBlock(w.data, w.zdata[checksumSize+headerSize:])
+	if err != nil {
+		return err
+	}
+	if n == 0 || n >= len(w.data) {
+		// incompressible data
+		method = methodNone
+		n = copy(w.zdata[checksumSize+headerSize:], w.data)
+	}
+	block := w.zdata[checksumSize : checksumSize+headerSize+n]
+	block[0] = method
+	binary.LittleEndian.PutUint32(block[1:], uint32(headerSize+n))
+	binary.LittleEndian.PutUint32(block[5:], uint32(len(w.data)))
+	checksum := cityhash102.CityHash128(block, uint32(len(block)))
+	binary.LittleEndian.PutUint64(w.zdata[0:], checksum.Lower64())
+	binary.LittleEndian.PutUint64(w.zdata[8:], checksum.Higher64())
+
+	w.data = w.data[:0]
+	_, err = w.w.Write(w.zdata[:checksumSize+len(block)])
+	return err
+}
+
+// Close flush pending data (underlying writer is not closed)
+func (w *BlockWriter) Close() error {
+	return w.Flush()
+}
+
+// BlockReader read ClickHouse native compressed blocks
+type BlockReader struct {
+	r      io.Reader
+	header [checksumSize + headerSize]byte
+	data   []byte
+	zdata  []byte
+	pos    int
+}
+
+func NewBlockReader(r io.Reader) *BlockReader {
+	return &BlockReader{r: r}
+}
+
+func (r *BlockReader) Read(p []byte) (int, error) {
+	if r.pos == len(r.data) {
+		if err := r.readBlock(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func (r *BlockReader) readBlock() error {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return ErrBlockHeader
+		}
+		return err
+	}
+	compressedSize := int(binary.LittleEndian.Uint32(r.header[checksumSize+1:])) - headerSize
+	size := int(binary.LittleEndian.Uint32(r.header[checksumSize+5:]))
+	if compressedSize < 0 || compressedSize > maxReadBlockSize || size > maxReadBlockSize {
+		return ErrBlockHeader
+	}
+	if cap(r.zdata) < headerSize+compressedSize {
+		r.zdata = make([]byte, headerSize+compressedSize)
+	}
+	r.zdata = r.zdata[:headerSize+compressedSize]
+	copy(r.zdata, r.header[checksumSize:])
+	if _, err := io.ReadFull(r.r, r.zdata[headerSize:]); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	checksum := cityhash102.CityHash128(r.zdata, uint32(len(r.zdata)))
+	if binary.LittleEndian.Uint64(r.header[0:]) != checksum.Lower64() ||
+		binary.LittleEndian.Uint64(r.header[8:]) != checksum.Higher64() {
+		return ErrChecksum
+	}
+
+	if cap(r.data) < size {
+		r.data = make([]byte, size)
+	}
+	r.data = r.data[:size]
+	r.pos = 0
+	switch r.header[checksumSize] {
+	case methodNone:
+		if compressedSize != size {
+			return ErrBlockHeader
+		}
+		copy(r.data, r.zdata[headerSize:])
+	case methodLZ4:
+		n, err := lz4.UncompressBlock(r.zdata[headerSize:], r.data)
+		if err != nil {
+			return err
+		}
+		if n != size {
+			return ErrBlockHeader
+		}
+	default:
+		return fmt.Errorf("%w: unknown method 0x%02x", ErrBlockHeader, r.header[checksumSize])
+	}
+	return nil
+}
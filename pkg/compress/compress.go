@@ -0,0 +1,84 @@
+package compress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression methods
+const (
+	None = "none"
+	LZ4  = "lz4" // ClickHouse native compressed blocks (LZ4 with CityHash128 checksums)
+	ZSTD = "zstd"
+	Gzip = "gzip"
+)
+
+var ErrMethodNotSupported = fmt.Errorf("compression method not supported")
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// NewWriter return compressing writer. Close flush pending data, but not close underlying writer.
+func NewWriter(w io.Writer, method string) (io.WriteCloser, error) {
+	switch method {
+	case "", None:
+		return nopCloser{w}, nil
+	case LZ4:
+		return NewBlockWriter(w), nil
+	case ZSTD:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	case Gzip:
+		return gzip.NewWriterLevel(w, gzip.BestSpeed)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrMethodNotSupported, method)
+	}
+}
+
+// NewReader return decompressing reader
+func NewReader(r io.Reader, method string) (io.ReadCloser, error) {
+	switch method {
+	case "", None:
+		return io.NopCloser(r), nil
+	case LZ4:
+		return io.NopCloser(NewBlockReader(r)), nil
+	case ZSTD:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case Gzip:
+		return gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrMethodNotSupported, method)
+	}
+}
+
+// ContentEncoding return HTTP Content-Encoding for method
+// (empty for none and ClickHouse native compression, enabled by decompress=1 query param)
+func ContentEncoding(method string) string {
+	switch method {
+	case ZSTD, Gzip:
+		return method
+	default:
+		return ""
+	}
+}
+
+// CountWriter count bytes, written to underlying writer
+type CountWriter struct {
+	W io.Writer
+	N uint64
+}
+
+func (w *CountWriter) Write(p []byte) (int, error) {
+	n, err := w.W.Write(p)
+	w.N += uint64(n)
+	return n, err
+}
@@ -0,0 +1,75 @@
+package compress
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	random := make([]byte, 3*maxBlockSize/2)
+	rand.New(rand.NewSource(1)).Read(random)
+	tests := map[string][]byte{
+		"empty":        {},
+		"small":        []byte("cpu.loadavg;env=test;host=host1"),
+		"repetitive":   []byte(strings.Repeat("cpu.loadavg?env=test&host=host1", 100000)),
+		"incompressed": random,
+	}
+	for _, method := range []string{None, LZ4, ZSTD, Gzip} {
+		for name, data := range tests {
+			t.Run(method+"/"+name, func(t *testing.T) {
+				var buf bytes.Buffer
+				w, err := NewWriter(&buf, method)
+				require.NoError(t, err)
+				// write by parts
+				for i := 0; i < len(data); i += 100000 {
+					end := i + 100000
+					if end > len(data) {
+						end = len(data)
+					}
+					_, err = w.Write(data[i:end])
+					require.NoError(t, err)
+				}
+				require.NoError(t, w.Close())
+				if name == "repetitive" && method != None {
+					assert.Less(t, buf.Len(), len(data)/10)
+				}
+
+				r, err := NewReader(&buf, method)
+				require.NoError(t, err)
+				got, err := ioutil.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, len(data), len(got))
+				assert.True(t, bytes.Equal(data, got))
+			})
+		}
+	}
+}
+
+func TestBlockReaderChecksum(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewBlockWriter(&buf)
+	_, err := w.Write([]byte(strings.Repeat("test", 100)))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff
+	_, err = ioutil.ReadAll(NewBlockReader(bytes.NewReader(data)))
+	assert.Equal(t, ErrChecksum, err)
+
+	_, err = ioutil.ReadAll(NewBlockReader(bytes.NewReader(data[:10])))
+	assert.Equal(t, ErrBlockHeader, err)
+}
+
+func TestNotSupported(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "snappy")
+	assert.ErrorIs(t, err, ErrMethodNotSupported)
+	assert.Equal(t, "gzip", ContentEncoding(Gzip))
+	assert.Equal(t, "", ContentEncoding(LZ4))
+}
@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// ClickHouseOptions return clickhouse-go (native protocol) options for DSN
+func ClickHouseOptions(dsn *DSN) (*clickhouse.Options, error) {
+	tlsConfig, err := dsn.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	defaultPort := "9000"
+	if dsn.Secure {
+		defaultPort = "9440"
+	}
+	opts := &clickhouse.Options{
+		TLS:  tlsConfig,
+		Addr: dsn.Addrs("127.0.0.1", defaultPort),
+		Auth: clickhouse.Auth{
+			Database: dsn.Database,
+			Username: dsn.Username,
+			Password: dsn.Password,
+		},
+		Settings: make(clickhouse.Settings, len(dsn.Settings)),
+		//Debug:           true,
+		DialTimeout:     time.Second,
+		MaxOpenConns:    2,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Hour,
+	}
+	for k, v := range dsn.Settings {
+		opts.Settings[k] = v
+	}
+	switch dsn.CompressOr(CompressNone) {
+	case CompressNone:
+	case CompressLZ4:
+		opts.Compression = &clickhouse.Compression{Method: clickhouse.CompressionLZ4}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrCompressNotSupported, dsn.Compress)
+	}
+	return opts, nil
+}
@@ -3,37 +3,134 @@ package mailru
 import (
 	"context"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
 	"github.com/tevino/abool"
-	"github.com/vahid-sohrabloo/chconn"
+	"github.com/vahid-sohrabloo/chconn/chpool"
 	"github.com/vahid-sohrabloo/chconn/column"
 	// "github.com/vahid-sohrabloo/chconn/column"
 )
 
+func init() {
+	driver.Register(driver.Registration{
+		Name: "columnar",
+		Caps: driver.CapTagged,
+		New:  newDriver,
+	})
+}
+
+func newDriver(kind driver.Capability, cfg driver.Config) (driver.Driver, error) {
+	d, err := NewTaggedDriver(cfg.DSN, cfg.Table, cfg.FlushSize)
+	if err != nil {
+		return nil, err
+	}
+	d.parse = cfg.TagsMode.Parser()
+	d.tag1 = cfg.Tag1
+	return d, nil
+}
+
 type TaggedDriver struct {
-	address string
-	table   string
+	table    string
+	settings string // SETTINGS clause for insert query
+
+	pool   chpool.Pool
+	broken bool // last flush failed, check connection before next
 
 	flushSize uint // metrics max size in bytes
 
+	parse tags.ParseFunc     // tagged metric parser
+	tag1  *driver.Tag1Policy // Tag1 rows policy (nil for all tags)
+
 	size    uint                 // size (for flush detect)
 	metrics []driver.MetricIndex // metrics buffer
 
 	isRunning *abool.AtomicBool
 }
 
-func NewTaggedDriver(address, table string, flushSize uint) (*TaggedDriver, error) {
-	if len(address) == 0 {
-		address = "clickhouse://127.0.0.1:9000/default"
+// connString convert DSN to chconn connection string (TLS is configured separately)
+func connString(dsn *driver.DSN) string {
+	defaultPort := "9000"
+	if dsn.Secure {
+		defaultPort = "9440"
+	}
+	addrs := dsn.Addrs("127.0.0.1", defaultPort)
+	hosts := make([]string, len(addrs))
+	ports := make([]string, len(addrs))
+	for i, addr := range addrs {
+		hosts[i], ports[i], _ = net.SplitHostPort(addr)
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	params := [][2]string{
+		{"host", strings.Join(hosts, ",")},
+		{"port", strings.Join(ports, ",")},
+		{"database", dsn.Database},
+		{"user", dsn.Username},
+		{"password", dsn.Password},
+	}
+	var sb strings.Builder
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(p[0])
+		sb.WriteString("='")
+		sb.WriteString(quote.Replace(p[1]))
+		sb.WriteByte('\'')
+	}
+	return sb.String()
+}
+
+func NewTaggedDriver(dsn *driver.DSN, table string, flushSize uint) (*TaggedDriver, error) {
+	config, err := chpool.ParseConfig(connString(dsn))
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := dsn.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		config.ConnConfig.TLSConfig = tlsConfig.Clone()
+		config.ConnConfig.TLSConfig.ServerName = config.ConnConfig.Host
+		for _, fallback := range config.ConnConfig.Fallbacks {
+			fallback.TLSConfig = tlsConfig.Clone()
+			fallback.TLSConfig.ServerName = fallback.Host
+		}
+	}
+	switch dsn.CompressOr(driver.CompressNone) {
+	case driver.CompressNone:
+	case driver.CompressLZ4:
+		config.ConnConfig.Compress = true
+	default:
+		return nil, fmt.Errorf("%w: %s", driver.ErrCompressNotSupported, dsn.Compress)
 	}
+	config.MaxConns = 2
+	config.MaxConnLifetime = time.Hour
+	ctx := context.Background()
+	pool, err := chpool.ConnectConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
 	return &TaggedDriver{
-		address:   address,
 		table:     table,
+		settings:  dsn.SettingsClause(),
+		pool:      pool,
 		flushSize: flushSize,
+		parse:     tags.TagsParse,
 		metrics: make(
 			[]driver.MetricIndex,
 			0, flushSize/100, // some evristic: size / avg metric length
@@ -45,14 +142,15 @@ func (d *TaggedDriver) Queued() uint {
 	return d.size
 }
 
-func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error) {
-	var duration time.Duration
-	var n uint
-	var err error
+func (d *TaggedDriver) Write(m driver.MetricIndex) (driver.FlushResult, error) {
+	var (
+		result driver.FlushResult
+		err    error
+	)
 	// fmt.Printf("%s %v\n", m.Metric, m.Date)
 	if d.size >= d.flushSize {
-		if duration, n, err = d.Flush(); err != nil {
-			return duration, n, err
+		if result, err = d.Flush(); err != nil {
+			return result, err
 		}
 	}
 
@@ -60,20 +158,26 @@ func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error)
 		d.metrics = append(d.metrics, m)
 		d.size += uint(len(m.Metric))
 	} else {
-		return d.Flush()
+		// end of input, keep threshold flush result
+		r, err := d.Flush()
+		result.Add(r)
+		return result, err
 	}
 
-	return duration, n, nil
+	return result, nil
 }
 
-func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
-	var n uint
-	start := time.Now()
+func (d *TaggedDriver) Flush() (driver.FlushResult, error) {
+	result := driver.FlushResult{Start: time.Now()}
 	if d.size > 0 {
 		ctx := context.Background()
-		conn, err := chconn.Connect(ctx, d.address)
-		if err != nil {
-			return 0, 0, err
+		if d.broken {
+			// pool health check drop broken connections, so ping establish a new one
+			if err := d.pool.Ping(ctx); err != nil {
+				result.Duration = time.Since(result.Start)
+				return result, err
+			}
+			d.broken = false
 		}
 
 		// fmt.Println("FLUSH")
@@ -86,11 +190,15 @@ func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
 		tagsCols := column.NewArray(tagsValues)
 
 		for _, m := range d.metrics {
-			if path, tags, err := tags.TagsParse(m.Metric); err != nil {
+			if path, tags, err := d.parse(m.Metric); err != nil {
 				fmt.Fprintf(os.Stderr, "invalid metric '%s': %v", m.Metric, err)
+				result.Rejected++
 			} else {
 				// fmt.Printf("%s %+v %v\n", name, tags, m.Date)
 				for _, tag1 := range tags {
+					if !d.tag1.Emit(tag1) {
+						continue
+					}
 					dateCols.Append(m.Date)
 					tag1Cols.AppendString(tag1)
 					pathCols.AppendString(path)
@@ -100,21 +208,27 @@ func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
 						tagsValues.AppendString(tag)
 					}
 				}
-				n++
+				result.Metrics++
+				result.Rows += uint(d.tag1.Count(tags))
 			}
 		}
 
-		err = conn.Insert(ctx, "INSERT INTO "+d.table+" (Date, Tag1, Path, Tags, Version) VALUES", dateCols, tag1Cols, pathCols, tagsCols, versionCols)
+		err := d.pool.Insert(ctx, "INSERT INTO "+d.table+" ("+driver.TaggedCodec.Columns()+")"+d.settings+" VALUES", dateCols, tag1Cols, pathCols, tagsCols, versionCols)
 		if err != nil {
-			return 0, 0, err
+			d.broken = true
+			result.Fail()
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
 
 		d.metrics = d.metrics[:0]
 		d.size = 0
 	}
-	return time.Since(start), n, nil
+	result.Duration = time.Since(result.Start)
+	return result, nil
 }
 
 func (d *TaggedDriver) Close() error {
+	d.pool.Close()
 	return nil
 }
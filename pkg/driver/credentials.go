@@ -0,0 +1,205 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Environment variables with ClickHouse credentials
+const (
+	EnvUser     = "CLICKHOUSE_USER"
+	EnvPassword = "CLICKHOUSE_PASSWORD"
+)
+
+// Credentials sources. Credentials from address take precedence, after them
+// credentials file, environment variables and netrc are used for empty fields.
+type Credentials struct {
+	File  string // credentials file with user=... and password=... lines
+	Netrc string // netrc file, if empty $NETRC or ~/.netrc is used (if exist)
+}
+
+// checkPerm deny files, accessible by group or others (like ssh do)
+func checkPerm(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%s is accessible by group or others (mode %#o), must be 0600 or stricter", path, perm)
+	}
+	return nil
+}
+
+// readCredentialsFile parse key=value lines (user, password), empty lines and lines started with # are skipped
+func readCredentialsFile(path string) (user, password string, err error) {
+	if err = checkPerm(path); err != nil {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq == -1 {
+			return "", "", fmt.Errorf("%s:%d: invalid line, want key=value", path, n)
+		}
+		key := strings.TrimSpace(line[:eq])
+		value := strings.TrimSpace(line[eq+1:])
+		switch key {
+		case "user", "username":
+			user = value
+		case "password":
+			password = value
+		default:
+			return "", "", fmt.Errorf("%s:%d: unknown key '%s'", path, n, key)
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+type netrcEntry struct {
+	machine   string // empty for default entry
+	login     string
+	password  string
+	isDefault bool
+}
+
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	tokens := strings.Fields(data)
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine":
+			entries = append(entries, netrcEntry{})
+			if i+1 < len(tokens) {
+				i++
+				entries[len(entries)-1].machine = tokens[i]
+			}
+		case "default":
+			entries = append(entries, netrcEntry{isDefault: true})
+		case "login", "password", "account":
+			if i+1 == len(tokens) {
+				break
+			}
+			i++
+			if len(entries) == 0 {
+				continue
+			}
+			switch tokens[i-1] {
+			case "login":
+				entries[len(entries)-1].login = tokens[i]
+			case "password":
+				entries[len(entries)-1].password = tokens[i]
+			}
+		case "macdef":
+			// macros are not supported, macdef must be last in netrc
+			return entries
+		}
+	}
+	return entries
+}
+
+// netrcLookup find login and password for host (and login, if not empty) in netrc file
+func netrcLookup(path, host, login string) (string, string, bool, error) {
+	if err := checkPerm(path); err != nil {
+		return "", "", false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	var def *netrcEntry
+	entries := parseNetrc(string(data))
+	for i := range entries {
+		e := &entries[i]
+		if login != "" && e.login != login {
+			continue
+		}
+		if e.isDefault {
+			if def == nil {
+				def = e
+			}
+		} else if e.machine == host {
+			return e.login, e.password, true, nil
+		}
+	}
+	if def != nil {
+		return def.login, def.password, true, nil
+	}
+	return "", "", false, nil
+}
+
+func (d *DSN) setCredentials(user, password string) {
+	if d.Username == "" {
+		d.Username = user
+	}
+	if d.Password == "" {
+		d.Password = password
+	}
+}
+
+// LoadCredentials fill empty user and password from credentials sources
+func (d *DSN) LoadCredentials(c Credentials) error {
+	if c.File != "" {
+		user, password, err := readCredentialsFile(c.File)
+		if err != nil {
+			return err
+		}
+		d.setCredentials(user, password)
+	}
+
+	d.setCredentials(os.Getenv(EnvUser), os.Getenv(EnvPassword))
+
+	if d.Password != "" {
+		return nil
+	}
+	netrc := c.Netrc
+	if netrc == "" {
+		if netrc = os.Getenv("NETRC"); netrc == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil
+			}
+			netrc = filepath.Join(home, ".netrc")
+			if _, err := os.Stat(netrc); err != nil {
+				return nil
+			}
+		}
+	}
+	host := "127.0.0.1"
+	if len(d.Hosts) > 0 {
+		host = d.Hosts[0]
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.Trim(host, "[]")
+	}
+	login, password, ok, err := netrcLookup(netrc, host, d.Username)
+	if err != nil {
+		return err
+	}
+	if ok {
+		d.setCredentials(login, password)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string, perm os.FileMode) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), perm))
+	require.NoError(t, os.Chmod(path, perm))
+	return path
+}
+
+func TestLoadCredentialsFile(t *testing.T) {
+	os.Unsetenv(EnvUser)
+	os.Unsetenv(EnvPassword)
+
+	path := writeFile(t, "credentials", "# clickhouse\nuser = loader\npassword=secret\n", 0600)
+
+	dsn, err := ParseDSN("ch1:9000")
+	require.NoError(t, err)
+	assert.NoError(t, dsn.LoadCredentials(Credentials{File: path, Netrc: os.DevNull}))
+	assert.Equal(t, "loader", dsn.Username)
+	assert.Equal(t, "secret", dsn.Password)
+
+	// address take precedence
+	dsn, err = ParseDSN("admin@ch1:9000")
+	require.NoError(t, err)
+	assert.NoError(t, dsn.LoadCredentials(Credentials{File: path, Netrc: os.DevNull}))
+	assert.Equal(t, "admin", dsn.Username)
+	assert.Equal(t, "secret", dsn.Password)
+
+	insecure := writeFile(t, "insecure", "user=loader\npassword=secret\n", 0644)
+	assert.Error(t, dsn.LoadCredentials(Credentials{File: insecure}))
+
+	invalid := writeFile(t, "invalid", "secret\n", 0600)
+	assert.Error(t, dsn.LoadCredentials(Credentials{File: invalid}))
+}
+
+func TestLoadCredentialsEnv(t *testing.T) {
+	os.Setenv(EnvUser, "env_user")
+	os.Setenv(EnvPassword, "env_password")
+	defer os.Unsetenv(EnvUser)
+	defer os.Unsetenv(EnvPassword)
+
+	dsn, err := ParseDSN("ch1:9000")
+	require.NoError(t, err)
+	assert.NoError(t, dsn.LoadCredentials(Credentials{}))
+	assert.Equal(t, "env_user", dsn.Username)
+	assert.Equal(t, "env_password", dsn.Password)
+}
+
+func TestLoadCredentialsNetrc(t *testing.T) {
+	os.Unsetenv(EnvUser)
+	os.Unsetenv(EnvPassword)
+
+	netrc := writeFile(t, "netrc", `machine ch1 login loader password secret
+machine ch2
+	login admin
+	password admin_secret
+machine ch2 login reader password reader_secret
+default login default password default_secret
+macdef init
+	machine ch3 login other password other
+`, 0600)
+
+	tests := []struct {
+		address      string
+		wantUser     string
+		wantPassword string
+	}{
+		{address: "ch1:9000", wantUser: "loader", wantPassword: "secret"},
+		{address: "http://ch2:8123", wantUser: "admin", wantPassword: "admin_secret"},
+		{address: "reader@ch2:9000", wantUser: "reader", wantPassword: "reader_secret"},
+		{address: "ch3", wantUser: "default", wantPassword: "default_secret"},
+		{address: "reader@ch3", wantUser: "reader", wantPassword: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			dsn, err := ParseDSN(tt.address)
+			require.NoError(t, err)
+			assert.NoError(t, dsn.LoadCredentials(Credentials{Netrc: netrc}))
+			assert.Equal(t, tt.wantUser, dsn.Username)
+			assert.Equal(t, tt.wantPassword, dsn.Password)
+		})
+	}
+}
@@ -13,8 +13,8 @@ type MetricIndex struct {
 }
 
 type Driver interface {
-	Write(MetricIndex) (time.Duration, uint, error)
-	Flush() (time.Duration, uint, error)
+	Write(MetricIndex) (FlushResult, error)
+	Flush() (FlushResult, error)
 	Close() error
 	Queued() uint
 }
@@ -0,0 +1,301 @@
+package driver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/compress"
+)
+
+var ErrCompressNotSupported = fmt.Errorf("compression method not supported by driver")
+
+// Compression methods
+const (
+	CompressNone = compress.None
+	CompressLZ4  = compress.LZ4
+	CompressZSTD = compress.ZSTD
+	CompressGzip = compress.Gzip
+)
+
+// DSN is a ClickHouse connection settings, common for all drivers.
+//
+// Format: [scheme://][user[:password]@]host1[:port1][,host2[:port2]][/database][?param1=value1&...]
+//
+// Known params are database, user (username), password, secure, skip_verify,
+// tls_ca, tls_cert, tls_key, compress (none, lz4, zstd, gzip, true, false),
+// all other params are passed as ClickHouse settings.
+type DSN struct {
+	Scheme   string   // empty for driver default
+	Hosts    []string // host[:port] list, empty for driver default
+	Database string
+	Username string
+	Password string
+
+	Secure     bool   // use TLS
+	SkipVerify bool   // skip server certificate verification
+	CAFile     string // CA certificate file
+	CertFile   string // client certificate file
+	KeyFile    string // client certificate key file
+
+	Compress string // compression method, empty for driver default
+
+	Settings map[string]string // ClickHouse settings
+}
+
+func parseBool(key, value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value: %s", key, value)
+	}
+	return b, nil
+}
+
+// ParseDSN parse ClickHouse address
+func ParseDSN(address string) (*DSN, error) {
+	dsn := &DSN{Settings: make(map[string]string)}
+
+	if n := strings.Index(address, "://"); n >= 0 {
+		dsn.Scheme = strings.ToLower(address[:n])
+		address = address[n+3:]
+		if dsn.Scheme == "https" {
+			dsn.Secure = true
+		}
+	}
+
+	var rawQuery string
+	if n := strings.IndexByte(address, '?'); n >= 0 {
+		rawQuery = address[n+1:]
+		address = address[:n]
+	}
+	if n := strings.IndexByte(address, '/'); n >= 0 {
+		database, err := url.PathUnescape(strings.Trim(address[n+1:], "/"))
+		if err != nil {
+			return nil, err
+		}
+		dsn.Database = database
+		address = address[:n]
+	}
+	if n := strings.LastIndexByte(address, '@'); n >= 0 {
+		userInfo := address[:n]
+		address = address[n+1:]
+		user := userInfo
+		var err error
+		if i := strings.IndexByte(userInfo, ':'); i >= 0 {
+			user = userInfo[:i]
+			if dsn.Password, err = url.PathUnescape(userInfo[i+1:]); err != nil {
+				return nil, err
+			}
+		}
+		if dsn.Username, err = url.PathUnescape(user); err != nil {
+			return nil, err
+		}
+	}
+	if address != "" {
+		for _, host := range strings.Split(address, ",") {
+			if host == "" {
+				return nil, fmt.Errorf("empty host in clickhouse address")
+			}
+			dsn.Hosts = append(dsn.Hosts, host)
+		}
+	}
+
+	params, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range params {
+		value := values[len(values)-1]
+		switch key {
+		case "database":
+			dsn.Database = value
+		case "user", "username":
+			dsn.Username = value
+		case "password":
+			dsn.Password = value
+		case "secure":
+			if dsn.Secure, err = parseBool(key, value); err != nil {
+				return nil, err
+			}
+		case "skip_verify":
+			if dsn.SkipVerify, err = parseBool(key, value); err != nil {
+				return nil, err
+			}
+		case "tls_ca":
+			dsn.CAFile = value
+		case "tls_cert":
+			dsn.CertFile = value
+		case "tls_key":
+			dsn.KeyFile = value
+		case "compress":
+			switch strings.ToLower(value) {
+			case "", "1", "true":
+				dsn.Compress = CompressLZ4
+			case "0", "false", CompressNone:
+				dsn.Compress = CompressNone
+			case CompressLZ4, CompressZSTD, CompressGzip:
+				dsn.Compress = strings.ToLower(value)
+			default:
+				return nil, fmt.Errorf("invalid compress value: %s", value)
+			}
+		default:
+			dsn.Settings[key] = value
+		}
+	}
+
+	return dsn, nil
+}
+
+// Addrs return hosts list with ports, defaultPort is used for hosts without port
+// and defaultHost if hosts list is empty
+func (d *DSN) Addrs(defaultHost, defaultPort string) []string {
+	if len(d.Hosts) == 0 {
+		return []string{net.JoinHostPort(defaultHost, defaultPort)}
+	}
+	addrs := make([]string, len(d.Hosts))
+	for i, host := range d.Hosts {
+		if _, _, err := net.SplitHostPort(host); err == nil {
+			addrs[i] = host
+		} else {
+			addrs[i] = net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+		}
+	}
+	return addrs
+}
+
+// CompressOr return compression method or default, if not set
+func (d *DSN) CompressOr(defaultCompress string) string {
+	if d.Compress == "" {
+		return defaultCompress
+	}
+	return d.Compress
+}
+
+// TLSConfig return TLS settings or nil, if secure connection is not requested
+func (d *DSN) TLSConfig() (*tls.Config, error) {
+	if !d.Secure {
+		return nil, nil
+	}
+	cfg := &tls.Config{InsecureSkipVerify: d.SkipVerify}
+	if d.CAFile != "" {
+		pem, err := ioutil.ReadFile(d.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", d.CAFile)
+		}
+	}
+	if d.CertFile != "" || d.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(d.CertFile, d.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// SettingsKeys return sorted settings names
+func (d *DSN) SettingsKeys() []string {
+	keys := make([]string, 0, len(d.Settings))
+	for k := range d.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SettingsClause return settings as query clause, like " SETTINGS k1=1, k2='v2'"
+// (empty if no settings)
+func (d *DSN) SettingsClause() string {
+	if len(d.Settings) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(" SETTINGS ")
+	for i, k := range d.SettingsKeys() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		v := d.Settings[k]
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			sb.WriteString(v)
+		} else {
+			sb.WriteByte('\'')
+			sb.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v))
+			sb.WriteByte('\'')
+		}
+	}
+	return sb.String()
+}
+
+// HTTPPort return default ClickHouse HTTP interface port (8443 for secure connection)
+func (d *DSN) HTTPPort() string {
+	if d.Secure {
+		return "8443"
+	}
+	return "8123"
+}
+
+// HTTPURL return URL for ClickHouse HTTP interface with database and settings (without credentials)
+func (d *DSN) HTTPURL(defaultHost string) *url.URL {
+	u := &url.URL{Scheme: "http", Host: d.Addrs(defaultHost, d.HTTPPort())[0], Path: "/"}
+	if d.Secure {
+		u.Scheme = "https"
+	}
+	q := u.Query()
+	if d.Database != "" {
+		q.Set("database", d.Database)
+	}
+	for k, v := range d.Settings {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u
+}
+
+// SetHTTPAuth set credentials headers for ClickHouse HTTP interface
+func (d *DSN) SetHTTPAuth(h http.Header) {
+	if d.Username != "" {
+		h.Set("X-ClickHouse-User", d.Username)
+	}
+	if d.Password != "" {
+		h.Set("X-ClickHouse-Key", d.Password)
+	}
+}
+
+// String return DSN with hidden password (for logging)
+func (d *DSN) String() string {
+	var sb strings.Builder
+	if d.Scheme != "" {
+		sb.WriteString(d.Scheme)
+		sb.WriteString("://")
+	}
+	if d.Username != "" {
+		sb.WriteString(url.PathEscape(d.Username))
+		if d.Password != "" {
+			sb.WriteString(":***")
+		}
+		sb.WriteByte('@')
+	}
+	sb.WriteString(strings.Join(d.Hosts, ","))
+	if d.Database != "" {
+		sb.WriteByte('/')
+		sb.WriteString(url.PathEscape(d.Database))
+	}
+	return sb.String()
+}
@@ -0,0 +1,557 @@
+package file
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/Native"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/compress"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+)
+
+func init() {
+	driver.Register(driver.Registration{
+		Name:    "file",
+		Aliases: []string{"files"},
+		Caps:    driver.CapTagged,
+		New:     newDriver,
+	})
+}
+
+func newDriver(kind driver.Capability, cfg driver.Config) (driver.Driver, error) {
+	d, err := NewTaggedDriver(cfg.DSN, cfg.Table, cfg.FlushSize)
+	if err != nil {
+		return nil, err
+	}
+	d.parse = cfg.TagsMode.ReusableParser() // rows are encoded before next parse
+	d.tag1 = cfg.Tag1
+	return d, nil
+}
+
+// Output formats
+const (
+	FormatRowBinary = "rowbinary" // RowBinaryWithNamesAndTypes
+	FormatNative    = "native"
+	FormatTSV       = "tsv" // TabSeparated
+)
+
+var formats = map[string]string{
+	FormatRowBinary: "RowBinaryWithNamesAndTypes",
+	FormatNative:    "Native",
+	FormatTSV:       "TabSeparated",
+}
+
+// Driver params in address
+const (
+	paramPath    = "path"
+	paramFormat  = "format"
+	paramMaxSize = "max_size"
+	paramPrefix  = "prefix"
+)
+
+// ErrCompleteFile is returned by Flush, if rows are written, but data file is not renamed or .sql file is not written
+// (rows stay in file with .tmp suffix, metrics are not flushed again)
+var ErrCompleteFile = fmt.Errorf("data file not completed")
+
+// maxBlockRows is a max rows in Native block
+const maxBlockRows = 65536
+
+// defaultMaxSize is a default data file size for rotation
+const defaultMaxSize = 1024 * 1024 * 1024
+
+// TaggedDriver write tagged table rows to local files (with INSERT statement in .sql file for each),
+// like file:///var/tmp/load?format=native&max_size=256M&compress=gzip
+// or file://?path=/var/tmp/load&format=tsv (database is used in INSERT statement).
+// Files are rotated by size (after flush), incomplete file has .tmp suffix.
+// Each flush is written as complete compressed stream (gzip member or zstd frame), so file is truncated
+// to previous flush end on error and buffered metrics can be flushed again.
+type TaggedDriver struct {
+	dir      string
+	prefix   string
+	format   string
+	compress string
+	maxSize  uint64
+	query    string // INSERT statement
+	settings string // SETTINGS clause for INSERT statement
+
+	start time.Time // for files names
+	seq   int       // file sequence number
+
+	file *os.File
+	name string // current file name (without extension)
+	cw   *compress.CountWriter
+	zw   io.WriteCloser // flush compressor
+
+	enc *RowBinary.Encoder
+	buf []byte // TSV rows or Native block
+
+	flushSize uint // metrics max size in bytes
+
+	parse tags.ParseFunc     // tagged metric parser
+	tag1  *driver.Tag1Policy // Tag1 rows policy (nil for all tags)
+
+	size    uint                 // size (for flush detect)
+	metrics []driver.MetricIndex // metrics buffer
+}
+
+// outputDir return files directory from address
+func outputDir(dsn *driver.DSN) (string, error) {
+	if path := dsn.Settings[paramPath]; path != "" {
+		return path, nil
+	}
+	if dsn.Scheme != "file" {
+		return "", fmt.Errorf("output directory not set, use file:///path or %s param", paramPath)
+	}
+	// file:///var/tmp is parsed as database var/tmp, file://dir/subdir as host dir and database subdir
+	database := dsn.Database
+	dsn.Database = ""
+	switch len(dsn.Hosts) {
+	case 0:
+		if database == "" {
+			return "", fmt.Errorf("output directory not set")
+		}
+		return "/" + database, nil
+	case 1:
+		return filepath.Join(dsn.Hosts[0], database), nil
+	default:
+		return "", fmt.Errorf("multiply output directories not supported")
+	}
+}
+
+func NewTaggedDriver(dsn *driver.DSN, table string, flushSize uint) (*TaggedDriver, error) {
+	compressMethod := dsn.CompressOr(driver.CompressNone)
+	switch compressMethod {
+	case driver.CompressNone, driver.CompressGzip, driver.CompressZSTD:
+	default:
+		// LZ4 is a ClickHouse native compressed blocks, not a file compression
+		return nil, fmt.Errorf("%w: %s", driver.ErrCompressNotSupported, dsn.Compress)
+	}
+
+	// copy settings, driver params are not passed to INSERT statement
+	settings := make(map[string]string, len(dsn.Settings))
+	for k, v := range dsn.Settings {
+		settings[k] = v
+	}
+	dsnCopy := *dsn
+	dsnCopy.Settings = settings
+
+	dir, err := outputDir(&dsnCopy)
+	if err != nil {
+		return nil, err
+	}
+	delete(settings, paramPath)
+
+	format := strings.ToLower(settings[paramFormat])
+	if format == "" {
+		format = FormatRowBinary
+	}
+	if _, ok := formats[format]; !ok {
+		return nil, fmt.Errorf("invalid file format: %s", format)
+	}
+	delete(settings, paramFormat)
+
+	maxSize := driver.Size(defaultMaxSize)
+	if v, ok := settings[paramMaxSize]; ok {
+		if err = maxSize.Set(v); err != nil || maxSize == 0 {
+			return nil, fmt.Errorf("invalid %s: %s", paramMaxSize, v)
+		}
+		delete(settings, paramMaxSize)
+	}
+
+	prefix := table
+	if v, ok := settings[paramPrefix]; ok {
+		prefix = v
+		delete(settings, paramPrefix)
+	}
+	if prefix == "" || strings.ContainsRune(prefix, filepath.Separator) {
+		return nil, fmt.Errorf("invalid file prefix: '%s'", prefix)
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if dsnCopy.Database != "" {
+		table = dsnCopy.Database + "." + table
+	}
+
+	return &TaggedDriver{
+		dir:       dir,
+		prefix:    prefix,
+		format:    format,
+		compress:  compressMethod,
+		maxSize:   uint64(maxSize),
+		query:     "INSERT INTO " + table + " (" + driver.TaggedCodec.Columns() + ")",
+		settings:  dsnCopy.SettingsClause(),
+		start:     time.Now(),
+		enc:       RowBinary.NewEncoder(64 * 1024),
+		flushSize: flushSize,
+		parse:     tags.ModeNatural.ReusableParser(),
+		metrics: make(
+			[]driver.MetricIndex,
+			0, flushSize/100, // some evristic: size / avg metric length
+		),
+	}, nil
+}
+
+// fileName return file name (without extension) for sequence number
+func (d *TaggedDriver) fileName(seq int) string {
+	return filepath.Join(d.dir, d.prefix+"."+d.start.Format("20060102T150405")+"."+fmt.Sprintf("%04d", seq))
+}
+
+// dataFile return data file name
+func (d *TaggedDriver) dataFile(name string) string {
+	name += "." + d.format
+	switch d.compress {
+	case driver.CompressGzip:
+		name += ".gz"
+	case driver.CompressZSTD:
+		name += ".zst"
+	}
+	return name
+}
+
+// shellQuote quote string for POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sqlStatement return INSERT statement for data file (relative to files directory)
+func (d *TaggedDriver) sqlStatement(name string) string {
+	base := filepath.Base(d.dataFile(name))
+	var sb strings.Builder
+	sb.WriteString("-- load from files directory: clickhouse-client --queries-file ")
+	sb.WriteString(filepath.Base(name))
+	sb.WriteString(".sql")
+	command := "clickhouse-client --query " + shellQuote(d.query+d.settings+" FORMAT "+formats[d.format]) + " < " + shellQuote(base)
+	if !strings.ContainsAny(command, "\r\n") {
+		// shell hint can't be commented out with line breaks
+		sb.WriteString("\n-- or: ")
+		sb.WriteString(command)
+		if d.compress != driver.CompressNone {
+			sb.WriteString(" (decompressed)")
+		}
+	}
+	sb.WriteByte('\n')
+	sb.WriteString(d.query)
+	sb.WriteString(" FROM INFILE '")
+	sb.WriteString(strings.ReplaceAll(base, "'", "\\'"))
+	sb.WriteByte('\'')
+	if d.compress != driver.CompressNone {
+		sb.WriteString(" COMPRESSION '")
+		sb.WriteString(d.compress)
+		sb.WriteByte('\'')
+	}
+	sb.WriteString(d.settings)
+	sb.WriteString(" FORMAT ")
+	sb.WriteString(formats[d.format])
+	sb.WriteString(";\n")
+	return sb.String()
+}
+
+func (d *TaggedDriver) openFile() error {
+	d.seq++
+	d.name = d.fileName(d.seq)
+	f, err := os.OpenFile(d.dataFile(d.name)+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	d.file = f
+	d.cw = &compress.CountWriter{W: f}
+	return nil
+}
+
+// closeFile complete data file and write .sql file for it
+func (d *TaggedDriver) closeFile() error {
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	filename := d.dataFile(d.name)
+	if err != nil {
+		return err
+	}
+	if d.cw.N == 0 {
+		// nothing written (first flush failed)
+		return os.Remove(filename + ".tmp")
+	}
+	if err = os.Rename(filename+".tmp", filename); err != nil {
+		return err
+	}
+	return os.WriteFile(d.name+".sql", []byte(d.sqlStatement(d.name)), 0644)
+}
+
+func (d *TaggedDriver) Queued() uint {
+	return d.size
+}
+
+func (d *TaggedDriver) Write(m driver.MetricIndex) (driver.FlushResult, error) {
+	var (
+		result driver.FlushResult
+		err    error
+	)
+	if d.size >= d.flushSize {
+		if result, err = d.Flush(); err != nil {
+			return result, err
+		}
+	}
+
+	if len(m.Metric) > 0 {
+		d.metrics = append(d.metrics, m)
+		d.size += uint(len(m.Metric))
+	} else {
+		// end of input, keep threshold flush result
+		r, err := d.Flush()
+		result.Add(r)
+		return result, err
+	}
+
+	return result, nil
+}
+
+// appendTSVString append escaped string (quoted for array elements)
+func appendTSVString(buf []byte, s string, quoted bool) []byte {
+	if quoted {
+		buf = append(buf, '\'')
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf = append(buf, '\\', '\\')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		case 0:
+			buf = append(buf, '\\', '0')
+		case '\'':
+			if quoted {
+				buf = append(buf, '\\', '\'')
+			} else {
+				buf = append(buf, c)
+			}
+		default:
+			buf = append(buf, c)
+		}
+	}
+	if quoted {
+		buf = append(buf, '\'')
+	}
+	return buf
+}
+
+// appendTaggedTSV append TaggedRow rows (one per tag, emitted by Tag1 policy) in TabSeparated format
+func appendTaggedTSV(buf []byte, date time.Time, path string, tags []string, version uint32, tag1Policy *driver.Tag1Policy) []byte {
+	var start, end, rows int
+	for _, tag1 := range tags {
+		if !tag1Policy.Emit(tag1) {
+			continue
+		}
+		buf = append(buf, date.Format("2006-01-02")...)
+		buf = append(buf, '\t')
+		buf = appendTSVString(buf, tag1, false)
+		buf = append(buf, '\t')
+		buf = appendTSVString(buf, path, false)
+		buf = append(buf, '\t')
+		if rows == 0 {
+			start = len(buf)
+			buf = append(buf, '[')
+			for j, tag := range tags {
+				if j > 0 {
+					buf = append(buf, ',')
+				}
+				buf = appendTSVString(buf, tag, true)
+			}
+			buf = append(buf, ']')
+			end = len(buf)
+		} else {
+			buf = append(buf, buf[start:end]...)
+		}
+		buf = append(buf, '\t')
+		buf = strconv.AppendUint(buf, uint64(version), 10)
+		buf = append(buf, '\n')
+		rows++
+	}
+	return buf
+}
+
+// encode write metrics rows to data file
+func (d *TaggedDriver) encode(result *driver.FlushResult) error {
+	version := uint32(result.Start.Unix())
+
+	var (
+		dateCol    *Native.Date
+		tag1Col    *Native.String
+		pathCol    *Native.String
+		tagsCol    *Native.ArrayString
+		versionCol *Native.UInt32
+		block      *Native.Block
+		tagsBuf    []byte
+	)
+	writeBlock := func() error {
+		var err error
+		if d.buf, err = block.AppendTo(d.buf[:0]); err != nil {
+			return err
+		}
+		block.Reset()
+		result.RawBytes += uint64(len(d.buf))
+		_, err = d.zw.Write(d.buf)
+		return err
+	}
+	if d.format == FormatNative {
+		dateCol = Native.NewDate("Date")
+		tag1Col = Native.NewString("Tag1")
+		pathCol = Native.NewString("Path")
+		tagsCol = Native.NewArrayString("Tags")
+		versionCol = Native.NewUInt32("Version")
+		block = Native.NewBlock(dateCol, tag1Col, pathCol, tagsCol, versionCol)
+	}
+
+	for _, m := range d.metrics {
+		path, tags, err := d.parse(m.Metric)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid metric '%s': %v", m.Metric, err)
+			result.Rejected++
+			continue
+		}
+		switch d.format {
+		case FormatRowBinary:
+			n := d.enc.Len()
+			driver.EncodeTagged(d.enc, m.Date, path, tags, version, d.tag1)
+			result.RawBytes += uint64(d.enc.Len() - n)
+			if d.enc.Len() >= 512*1024 {
+				if _, err = d.enc.WriteTo(d.zw); err != nil {
+					return err
+				}
+			}
+		case FormatTSV:
+			n := len(d.buf)
+			d.buf = appendTaggedTSV(d.buf, m.Date, path, tags, version, d.tag1)
+			result.RawBytes += uint64(len(d.buf) - n)
+			if len(d.buf) >= 512*1024 {
+				if _, err = d.zw.Write(d.buf); err != nil {
+					return err
+				}
+				d.buf = d.buf[:0]
+			}
+		case FormatNative:
+			date := RowBinary.DateToUint16(m.Date)
+			tagsBuf = Native.AppendStrings(tagsBuf[:0], tags)
+			for _, tag1 := range tags {
+				if !d.tag1.Emit(tag1) {
+					continue
+				}
+				dateCol.AppendUint16(date)
+				tag1Col.Append(tag1)
+				pathCol.Append(path)
+				tagsCol.AppendEncoded(len(tags), tagsBuf)
+				versionCol.Append(version)
+			}
+			if dateCol.Rows() >= maxBlockRows {
+				if err = writeBlock(); err != nil {
+					return err
+				}
+			}
+		}
+		result.Metrics++
+		result.Rows += uint(d.tag1.Count(tags))
+	}
+
+	switch d.format {
+	case FormatRowBinary:
+		if _, err := d.enc.WriteTo(d.zw); err != nil {
+			return err
+		}
+	case FormatTSV:
+		if _, err := d.zw.Write(d.buf); err != nil {
+			return err
+		}
+		d.buf = d.buf[:0]
+	case FormatNative:
+		if dateCol.Rows() > 0 {
+			return writeBlock()
+		}
+	}
+	return nil
+}
+
+// rollback truncate data file to offset (end of previous flush), on truncate failure file is abandoned (with .tmp suffix)
+func (d *TaggedDriver) rollback(offset uint64, err error) error {
+	terr := d.file.Truncate(int64(offset))
+	if terr == nil {
+		_, terr = d.file.Seek(int64(offset), io.SeekStart)
+	}
+	if terr != nil {
+		d.file.Close()
+		d.file = nil
+		return fmt.Errorf("%w (rollback: %v)", err, terr)
+	}
+	d.cw.N = offset
+	return err
+}
+
+func (d *TaggedDriver) Flush() (driver.FlushResult, error) {
+	result := driver.FlushResult{Start: time.Now()}
+	if d.size > 0 {
+		if d.file == nil {
+			if err := d.openFile(); err != nil {
+				result.Duration = time.Since(result.Start)
+				return result, err
+			}
+		}
+		written := d.cw.N
+		d.enc.Reset()
+		d.buf = d.buf[:0]
+		zw, err := compress.NewWriter(d.cw, d.compress)
+		if err != nil {
+			result.Duration = time.Since(result.Start)
+			return result, err
+		}
+		d.zw = zw
+		if written == 0 && d.format == FormatRowBinary {
+			err = driver.TaggedCodec.WriteHeader(RowBinary.NewWriter(d.enc))
+		}
+		if err == nil {
+			err = d.encode(&result)
+		}
+		if cerr := d.zw.Close(); err == nil {
+			// complete compressed stream
+			err = cerr
+		}
+		if err != nil {
+			// drop partially written rows, metrics are kept for next flush
+			result = driver.FlushResult{Start: result.Start, Duration: time.Since(result.Start)}
+			return result, d.rollback(written, err)
+		}
+		// rows are written, metrics must not be flushed again
+		d.metrics = d.metrics[:0]
+		d.size = 0
+		result.Bytes = d.cw.N - written
+		if d.compress == driver.CompressNone {
+			result.RawBytes = 0
+		}
+		if d.cw.N >= d.maxSize {
+			name := d.dataFile(d.name)
+			if err = d.closeFile(); err != nil {
+				result.Duration = time.Since(result.Start)
+				return result, fmt.Errorf("%w %s: %v", ErrCompleteFile, name, err)
+			}
+		}
+	}
+	result.Duration = time.Since(result.Start)
+	return result, nil
+}
+
+func (d *TaggedDriver) Close() error {
+	return d.closeFile()
+}
@@ -0,0 +1,436 @@
+package file
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/compress"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+)
+
+var testMetrics = []string{
+	"test;env=prod;host=h1",
+	"test.b;dc=a\tb",
+	"test.c;q=it's",
+}
+
+func newTestDriver(t *testing.T, address string) *TaggedDriver {
+	dsn, err := driver.ParseDSN(address)
+	require.NoError(t, err)
+	d, err := NewTaggedDriver(dsn, "graphite_tagged", 1024)
+	require.NoError(t, err)
+	return d
+}
+
+func writeMetrics(t *testing.T, d *TaggedDriver, date time.Time) driver.FlushResult {
+	for _, m := range testMetrics {
+		_, err := d.Write(driver.MetricIndex{Metric: m, Date: date})
+		require.NoError(t, err)
+	}
+	result, err := d.Flush()
+	require.NoError(t, err)
+	return result
+}
+
+func TestOutputDir(t *testing.T) {
+	tests := []struct {
+		address  string
+		want     string
+		database string
+		wantErr  bool
+	}{
+		{address: "file:///var/tmp/load", want: "/var/tmp/load"},
+		{address: "file://load/graphite", want: "load/graphite"},
+		{address: "file://?path=/var/tmp", want: "/var/tmp"},
+		{address: "http://127.0.0.1/graphite?path=/var/tmp", want: "/var/tmp", database: "graphite"},
+		{address: "file://", wantErr: true},
+		{address: "http://127.0.0.1/graphite", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			dsn, err := driver.ParseDSN(tt.address)
+			require.NoError(t, err)
+			got, err := outputDir(dsn)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+				assert.Equal(t, tt.database, dsn.Database)
+			}
+		})
+	}
+}
+
+func TestTaggedDriverRowBinary(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDriver(t, "file://?path="+dir+"&compress=gzip&max_insert_threads=4")
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+
+	result := writeMetrics(t, d, date)
+	assert.Equal(t, uint(3), result.Metrics)
+	assert.Equal(t, uint(7), result.Rows)
+	assert.NotZero(t, result.Bytes)
+	assert.NotZero(t, result.RawBytes)
+
+	// not completed
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	require.NoError(t, d.Close())
+
+	files, err = filepath.Glob(filepath.Join(dir, "graphite_tagged.*.0001.rowbinary.gz"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	sql, err := os.ReadFile(strings.TrimSuffix(files[0], ".rowbinary.gz") + ".sql")
+	require.NoError(t, err)
+	assert.Contains(t, string(sql),
+		"\nINSERT INTO graphite_tagged (Date, Tag1, Path, Tags, Version) FROM INFILE '"+filepath.Base(files[0])+
+			"' COMPRESSION 'gzip' SETTINGS max_insert_threads=4 FORMAT RowBinaryWithNamesAndTypes;\n",
+	)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := compress.NewReader(f, compress.Gzip)
+	require.NoError(t, err)
+	r := RowBinary.NewReader(zr)
+	require.NoError(t, driver.TaggedCodec.ReadHeader(r))
+
+	var rows []driver.TaggedRow
+	for {
+		var row driver.TaggedRow
+		if err := driver.TaggedCodec.Unmarshal(r, &row); err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	require.Len(t, rows, 7)
+	assert.Equal(t, "__name__=test", rows[0].Tag1)
+	assert.Equal(t, "env=prod", rows[1].Tag1)
+	assert.Equal(t, []string{"__name__=test", "env=prod", "host=h1"}, rows[1].Tags)
+	assert.Equal(t, date.Format("2006-01-02"), rows[6].Date.Format("2006-01-02"))
+	assert.Equal(t, uint32(result.Start.Unix()), rows[6].Version)
+}
+
+func TestTaggedDriverTSV(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDriver(t, "file://?path="+dir+"&format=tsv&prefix=tagged")
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+
+	result := writeMetrics(t, d, date)
+	assert.Equal(t, uint(7), result.Rows)
+	assert.Zero(t, result.RawBytes)
+	require.NoError(t, d.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "tagged.*.tsv"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, lines, 7)
+	version := uint32(result.Start.Unix())
+	assert.Equal(t,
+		"2022-03-01\t__name__=test.b\ttest.b?dc=a\\tb\t['__name__=test.b','dc=a\\tb']\t"+strconv.FormatUint(uint64(version), 10),
+		lines[3],
+	)
+	assert.Equal(t,
+		"2022-03-01\tq=it's\ttest.c?q=it's\t['__name__=test.c','q=it\\'s']\t"+strconv.FormatUint(uint64(version), 10),
+		lines[6],
+	)
+}
+
+func TestTaggedDriverRotate(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDriver(t, "file://?path="+dir+"&format=native&max_size=1")
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+
+	for i := 0; i < 3; i++ {
+		result := writeMetrics(t, d, date)
+		assert.Equal(t, uint(7), result.Rows)
+		assert.NotZero(t, result.Bytes)
+	}
+	require.NoError(t, d.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.native"))
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	files, err = filepath.Glob(filepath.Join(dir, "*.sql"))
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	files, err = filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestTaggedDriverCompleteFileError(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDriver(t, "file://?path="+dir+"&format=tsv&max_size=1")
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+
+	// .sql file can't be written
+	require.NoError(t, os.Mkdir(d.fileName(1)+".sql", 0755))
+	for _, m := range testMetrics {
+		_, err := d.Write(driver.MetricIndex{Metric: m, Date: date})
+		require.NoError(t, err)
+	}
+	result, err := d.Flush()
+	require.ErrorIs(t, err, ErrCompleteFile)
+	assert.Equal(t, uint(3), result.Metrics)
+	assert.Equal(t, uint(7), result.Rows)
+	assert.Zero(t, d.Queued())
+
+	// metrics are not flushed again
+	result, err = d.Flush()
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	result = writeMetrics(t, d, date)
+	assert.Equal(t, uint(7), result.Rows)
+	require.NoError(t, d.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tsv"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{d.fileName(1) + ".tsv", d.fileName(2) + ".tsv"}, files)
+}
+
+func TestNewTaggedDriverErrors(t *testing.T) {
+	dir := t.TempDir()
+	for _, address := range []string{
+		"file://?path=" + dir + "&compress=lz4",
+		"file://?path=" + dir + "&format=json",
+		"file://?path=" + dir + "&max_size=0",
+		"file://?path=" + dir + "&prefix=a/b",
+	} {
+		t.Run(address, func(t *testing.T) {
+			dsn, err := driver.ParseDSN(address)
+			require.NoError(t, err)
+			_, err = NewTaggedDriver(dsn, "graphite_tagged", 1024)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTaggedDriverTag1Policy(t *testing.T) {
+	dir := t.TempDir()
+	d := newTestDriver(t, "file://?path="+dir+"&format=tsv&prefix=tagged")
+	d.tag1 = driver.NewTag1Policy([]string{"__name__"})
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+
+	result := writeMetrics(t, d, date)
+	assert.Equal(t, uint(len(testMetrics)), result.Metrics)
+	assert.Equal(t, uint(len(testMetrics)), result.Rows)
+	require.NoError(t, d.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "tagged.*.tsv"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, len(testMetrics))
+	for _, line := range lines {
+		assert.Contains(t, line, "\t__name__=")
+	}
+}
+
+func TestTaggedDriverWriteThresholdAndEnd(t *testing.T) {
+	dir := t.TempDir()
+	dsn, err := driver.ParseDSN("file://?path=" + dir + "&format=tsv&prefix=tagged")
+	require.NoError(t, err)
+	d, err := NewTaggedDriver(dsn, "graphite_tagged", 1)
+	require.NoError(t, err)
+
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+	result, err := d.Write(driver.MetricIndex{Metric: testMetrics[0], Date: date})
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	// threshold flush and end of input flush in one call
+	result, err = d.Write(driver.MetricIndex{})
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), result.Metrics)
+	assert.Equal(t, uint(3), result.Rows)
+	assert.NotZero(t, result.Bytes)
+	assert.False(t, result.Start.IsZero())
+	require.NoError(t, d.Close())
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "", want: "''"},
+		{s: "INSERT INTO t FORMAT Native", want: "'INSERT INTO t FORMAT Native'"},
+		{s: "SETTINGS a='b'", want: `'SETTINGS a='\''b'\'''`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, shellQuote(tt.s))
+		})
+	}
+
+	d := newTestDriver(t, "file://?path="+t.TempDir()+"&format=tsv&insert_deduplication_token=a'b")
+	sql := d.sqlStatement(d.fileName(1))
+	assert.Contains(t, sql, "\n-- or: clickhouse-client --query 'INSERT INTO graphite_tagged (Date, Tag1, Path, Tags, Version) SETTINGS insert_deduplication_token="+
+		`'\''a\'\''b'\'' FORMAT TabSeparated' < '`)
+
+	d = newTestDriver(t, "file://?path="+t.TempDir()+"&format=tsv&insert_deduplication_token=a%0Ab")
+	sql = d.sqlStatement(d.fileName(1))
+	assert.NotContains(t, sql, "-- or:")
+}
+
+// failWriter write n bytes and fail
+type failWriter struct {
+	w io.Writer
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.n {
+		w.n -= len(p)
+		return w.w.Write(p)
+	}
+	n, _ := w.w.Write(p[:w.n])
+	w.n = 0
+	return n, errors.New("write failed")
+}
+
+func TestTaggedDriverFlushError(t *testing.T) {
+	tests := []struct {
+		format   string
+		compress string
+	}{
+		{format: FormatTSV, compress: driver.CompressNone},
+		{format: FormatTSV, compress: driver.CompressGzip},
+		{format: FormatTSV, compress: driver.CompressZSTD},
+		{format: FormatRowBinary, compress: driver.CompressNone},
+		{format: FormatRowBinary, compress: driver.CompressGzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.compress, func(t *testing.T) {
+			dir := t.TempDir()
+			d := newTestDriver(t, "file://?path="+dir+"&format="+tt.format+"&compress="+tt.compress)
+			date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+
+			_, err := d.Write(driver.MetricIndex{Metric: testMetrics[0], Date: date})
+			require.NoError(t, err)
+			result, err := d.Flush()
+			require.NoError(t, err)
+			assert.Equal(t, uint(3), result.Rows)
+
+			// partial write
+			_, err = d.Write(driver.MetricIndex{Metric: testMetrics[2], Date: date})
+			require.NoError(t, err)
+			for _, n := range []int{0, 10} {
+				d.cw.W = &failWriter{w: d.file, n: n}
+				result, err = d.Flush()
+				require.Error(t, err)
+				assert.Zero(t, result.Rows)
+				assert.NotZero(t, d.Queued())
+			}
+
+			// retry
+			d.cw.W = d.file
+			result, err = d.Flush()
+			require.NoError(t, err)
+			assert.Equal(t, uint(1), result.Metrics)
+			assert.Equal(t, uint(2), result.Rows)
+			require.NoError(t, d.Close())
+
+			files, err := filepath.Glob(filepath.Join(dir, "graphite_tagged.*."+tt.format+"*"))
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			f, err := os.Open(files[0])
+			require.NoError(t, err)
+			defer f.Close()
+			zr, err := compress.NewReader(f, tt.compress)
+			require.NoError(t, err)
+
+			var tag1 []string
+			if tt.format == FormatTSV {
+				scanner := bufio.NewScanner(zr)
+				for scanner.Scan() {
+					tag1 = append(tag1, strings.Split(scanner.Text(), "\t")[1])
+				}
+				require.NoError(t, scanner.Err())
+			} else {
+				r := RowBinary.NewReader(zr)
+				require.NoError(t, driver.TaggedCodec.ReadHeader(r))
+				for {
+					var row driver.TaggedRow
+					if err := driver.TaggedCodec.Unmarshal(r, &row); err != nil {
+						require.ErrorIs(t, err, io.EOF)
+						break
+					}
+					tag1 = append(tag1, row.Tag1)
+				}
+			}
+			// metrics from failed flushes are written once
+			assert.Equal(t, []string{"__name__=test", "env=prod", "host=h1", "__name__=test.c", "q=it's"}, tag1)
+		})
+	}
+}
+
+func BenchmarkTaggedDriverFlush(b *testing.B) {
+	metrics := make([]driver.MetricIndex, 1000)
+	date := time.Now()
+	for i := range metrics {
+		metrics[i] = driver.MetricIndex{
+			Metric: "test.metric.path" + strconv.Itoa(i) + ";host=host" + strconv.Itoa(i) + ";instance=instance;dc=dc1;env=prod;job=node",
+			Date:   date,
+		}
+	}
+	for _, bb := range []struct {
+		name  string
+		parse tags.ParseFunc
+	}{
+		{name: "TagsParse", parse: tags.TagsParse},
+		{name: "TagsParser", parse: tags.ModeNatural.ReusableParser()},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			dsn, err := driver.ParseDSN("file://?path=" + b.TempDir() + "&max_size=16G")
+			require.NoError(b, err)
+			d, err := NewTaggedDriver(dsn, "graphite_tagged", 1024*1024*1024)
+			require.NoError(b, err)
+			defer d.Close()
+			d.parse = bb.parse
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				for _, m := range metrics {
+					if _, err := d.Write(m); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := d.Flush(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/compress"
+)
+
+// HTTPInserter send INSERT queries with streamed (and compressed) body to ClickHouse HTTP interface
+type HTTPInserter struct {
+	dsn      *DSN
+	compress string // request body compression method
+	query    string
+	pingURL  string
+
+	client *http.Client
+	broken bool // last insert failed, check connection before next
+}
+
+// NewHTTPInserter create inserter for query (like INSERT INTO table (columns) FORMAT Native) and check server with ping
+func NewHTTPInserter(dsn *DSN, query string) (*HTTPInserter, error) {
+	compressMethod := dsn.CompressOr(CompressNone)
+	switch compressMethod {
+	case CompressNone, CompressGzip, CompressZSTD, CompressLZ4:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrCompressNotSupported, dsn.Compress)
+	}
+	tlsConfig, err := dsn.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	p := dsn.HTTPURL("127.0.0.1")
+	q := p.Query()
+
+	q.Set("query", query)
+	if compressMethod == CompressLZ4 {
+		// ClickHouse native compressed blocks
+		q.Set("decompress", "1")
+	}
+	p.RawQuery = q.Encode()
+	insertURL := p.String()
+
+	p.Path = "/ping"
+	p.RawQuery = ""
+
+	h := &HTTPInserter{
+		dsn:      dsn,
+		compress: compressMethod,
+		query:    insertURL,
+		pingURL:  p.String(),
+		client: &http.Client{
+			Timeout: time.Second * 60,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     tlsConfig,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     time.Minute,
+			},
+		},
+	}
+	if err = h.Ping(); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// Ping check clickhouse HTTP interface availability
+func (h *HTTPInserter) Ping() error {
+	req, err := http.NewRequest("GET", h.pingURL, nil)
+	if err != nil {
+		return err
+	}
+	h.dsn.SetHTTPAuth(req.Header)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("clickhouse ping status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// Insert send request with body, written by encode (in separate goroutine).
+// encode update result counters (RawBytes is reset if compression is not used),
+// Bytes and Summary are set from request.
+func (h *HTTPInserter) Insert(result *FlushResult, encode func(w io.Writer) error) error {
+	if h.broken {
+		// drop possible broken keep-alive connections and check server
+		h.client.CloseIdleConnections()
+		if err := h.Ping(); err != nil {
+			return err
+		}
+		h.broken = false
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	cw := &compress.CountWriter{W: pw}
+	zw, err := compress.NewWriter(cw, h.compress)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(done)
+		defer pw.Close()
+
+		if err := encode(zw); err != nil {
+			zw.Close()
+			pw.CloseWithError(err)
+			return
+		}
+		if err := zw.Close(); err != nil {
+			pw.CloseWithError(err)
+		}
+	}()
+
+	req, err := http.NewRequest("POST", h.query, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		<-done
+		return err
+	}
+	h.dsn.SetHTTPAuth(req.Header)
+	if encoding := compress.ContentEncoding(h.compress); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := h.client.Do(req)
+	// unblock writer goroutine, if request body is not fully consumed
+	pr.Close()
+	<-done
+	result.Bytes = cw.N
+	if h.compress == CompressNone {
+		result.RawBytes = 0
+	}
+	if err != nil {
+		h.broken = true
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("clickhouse response status %d: %s", resp.StatusCode, string(body))
+	}
+	result.Summary, _ = ParseSummary(resp.Header.Get("X-ClickHouse-Summary"))
+	return nil
+}
+
+// Close close idle connections
+func (h *HTTPInserter) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,130 @@
+package driver
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/compress"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClickHouse is a fake ClickHouse HTTP interface, store last inserted body
+type testClickHouse struct {
+	query    string
+	body     string
+	status   int
+	pings    int
+	inserted int
+}
+
+func (s *testClickHouse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ping" {
+		s.pings++
+		io.WriteString(w, "Ok.\n")
+		return
+	}
+	s.query = r.URL.Query().Get("query")
+	zr, err := compress.NewReader(r.Body, methodByEncoding(r.Header.Get("Content-Encoding")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.body = string(body)
+	if s.status != 0 && s.status != http.StatusOK {
+		http.Error(w, "insert failed", s.status)
+		return
+	}
+	s.inserted++
+	w.Header().Set("X-ClickHouse-Summary", `{"read_rows":"0","read_bytes":"0","written_rows":"2","written_bytes":"10"}`)
+}
+
+func methodByEncoding(encoding string) string {
+	switch encoding {
+	case "gzip":
+		return CompressGzip
+	case "zstd":
+		return CompressZSTD
+	}
+	return CompressNone
+}
+
+func TestHTTPInserter(t *testing.T) {
+	for _, compressMethod := range []string{CompressNone, CompressGzip, CompressZSTD} {
+		t.Run(compressMethod, func(t *testing.T) {
+			ch := &testClickHouse{}
+			srv := httptest.NewServer(ch)
+			defer srv.Close()
+
+			dsn, err := ParseDSN(srv.URL + "?compress=" + compressMethod)
+			require.NoError(t, err)
+			h, err := NewHTTPInserter(dsn, "INSERT INTO test FORMAT TSV")
+			require.NoError(t, err)
+			defer h.Close()
+			assert.Equal(t, 1, ch.pings)
+
+			result := FlushResult{RawBytes: 6}
+			err = h.Insert(&result, func(w io.Writer) error {
+				_, err := io.WriteString(w, "a\t1\nb\t2\n")
+				return err
+			})
+			require.NoError(t, err)
+			assert.Equal(t, "INSERT INTO test FORMAT TSV", ch.query)
+			assert.Equal(t, "a\t1\nb\t2\n", ch.body)
+			assert.NotZero(t, result.Bytes)
+			if compressMethod == CompressNone {
+				assert.Zero(t, result.RawBytes)
+			} else {
+				assert.Equal(t, uint64(6), result.RawBytes)
+			}
+			require.NotNil(t, result.Summary)
+			assert.Equal(t, uint64(2), result.Summary.WrittenRows)
+		})
+	}
+}
+
+func TestHTTPInserterErrors(t *testing.T) {
+	ch := &testClickHouse{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(ch)
+
+	dsn, err := ParseDSN(srv.URL)
+	require.NoError(t, err)
+	h, err := NewHTTPInserter(dsn, "INSERT INTO test FORMAT TSV")
+	require.NoError(t, err)
+	defer h.Close()
+
+	var result FlushResult
+	err = h.Insert(&result, func(w io.Writer) error {
+		_, err := io.WriteString(w, "a\t1\n")
+		return err
+	})
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "status 500"), err)
+
+	encodeErr := errors.New("encode failed")
+	err = h.Insert(&result, func(w io.Writer) error {
+		return encodeErr
+	})
+	assert.Error(t, err)
+	assert.Zero(t, ch.inserted)
+
+	// server down, next insert check connection with ping
+	srv.Close()
+	err = h.Insert(&result, func(w io.Writer) error { return nil })
+	assert.Error(t, err)
+	assert.True(t, h.broken)
+	assert.Error(t, h.Insert(&result, func(w io.Writer) error { return nil }))
+
+	_, err = NewHTTPInserter(&DSN{Scheme: "http", Compress: "snappy"}, "INSERT")
+	assert.True(t, errors.Is(err, ErrCompressNotSupported), err)
+}
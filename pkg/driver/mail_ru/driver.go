@@ -12,26 +12,85 @@ import (
 	"github.com/tevino/abool"
 )
 
+func init() {
+	driver.Register(driver.Registration{
+		Name: "mail.ru",
+		Caps: driver.CapTagged,
+		New:  newDriver,
+	})
+}
+
+func newDriver(kind driver.Capability, cfg driver.Config) (driver.Driver, error) {
+	d, err := NewTaggedDriver(cfg.DSN, cfg.Table, cfg.FlushSize)
+	if err != nil {
+		return nil, err
+	}
+	d.parse = cfg.TagsMode.Parser()
+	d.tag1 = cfg.Tag1
+	return d, nil
+}
+
 type TaggedDriver struct {
-	address string
-	table   string
+	table string
+
+	conn   *sql.DB
+	broken bool // last flush failed, check connection before next
 
 	flushSize uint // metrics max size in bytes
 
+	parse tags.ParseFunc     // tagged metric parser
+	tag1  *driver.Tag1Policy // Tag1 rows policy (nil for all tags)
+
 	size    uint                 // size (for flush detect)
 	metrics []driver.MetricIndex // metrics buffer
 
 	isRunning *abool.AtomicBool
 }
 
-func NewTaggedDriver(address, table string, flushSize uint) (*TaggedDriver, error) {
-	if len(address) == 0 {
-		address = "http://127.0.0.1:8123/default"
+func NewTaggedDriver(dsn *driver.DSN, table string, flushSize uint) (*TaggedDriver, error) {
+	cfg := clickhouse.NewConfig()
+	cfg.Host = dsn.Addrs("127.0.0.1", dsn.HTTPPort())[0]
+	if dsn.Secure {
+		cfg.Scheme = "https"
+		tlsConfig, err := dsn.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = "carbon-clickhouse-loader"
+		if err = clickhouse.RegisterTLSConfig(cfg.TLSConfig, tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	cfg.Database = dsn.Database
+	cfg.User = dsn.Username
+	cfg.Password = dsn.Password
+	for k, v := range dsn.Settings {
+		cfg.Params[k] = v
+	}
+	switch dsn.CompressOr(driver.CompressNone) {
+	case driver.CompressNone:
+	case driver.CompressGzip:
+		cfg.GzipCompression = true
+	default:
+		return nil, fmt.Errorf("%w: %s", driver.ErrCompressNotSupported, dsn.Compress)
+	}
+
+	conn, err := sql.Open("chhttp", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(2)
+	conn.SetConnMaxLifetime(time.Hour)
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
 	}
+
 	return &TaggedDriver{
-		address:   address,
 		table:     table,
+		conn:      conn,
 		flushSize: flushSize,
+		parse:     tags.TagsParse,
 		metrics: make(
 			[]driver.MetricIndex,
 			0, flushSize/100, // some evristic: size / avg metric length
@@ -43,14 +102,15 @@ func (d *TaggedDriver) Queued() uint {
 	return d.size
 }
 
-func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error) {
-	var duration time.Duration
-	var n uint
-	var err error
+func (d *TaggedDriver) Write(m driver.MetricIndex) (driver.FlushResult, error) {
+	var (
+		result driver.FlushResult
+		err    error
+	)
 	// fmt.Printf("%s %v\n", m.Metric, m.Date)
 	if d.size >= d.flushSize {
-		if duration, n, err = d.Flush(); err != nil {
-			return duration, n, err
+		if result, err = d.Flush(); err != nil {
+			return result, err
 		}
 	}
 
@@ -58,40 +118,53 @@ func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error)
 		d.metrics = append(d.metrics, m)
 		d.size += uint(len(m.Metric))
 	} else {
-		return d.Flush()
+		// end of input, keep threshold flush result
+		r, err := d.Flush()
+		result.Add(r)
+		return result, err
 	}
 
-	return duration, n, nil
+	return result, nil
 }
 
-func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
-	var n uint
-	start := time.Now()
+func (d *TaggedDriver) Flush() (driver.FlushResult, error) {
+	result := driver.FlushResult{Start: time.Now()}
 	if d.size > 0 {
-		connect, err := sql.Open("chhttp", d.address)
-		if err != nil {
-			return 0, 0, err
-		}
-		if err := connect.Ping(); err != nil {
-			return 0, 0, err
+		if d.broken {
+			// bad connections are dropped by pool, so ping establish a new one
+			if err := d.conn.Ping(); err != nil {
+				result.Duration = time.Since(result.Start)
+				return result, err
+			}
+			d.broken = false
 		}
-		tx, err := connect.Begin()
+		tx, err := d.conn.Begin()
 		if err != nil {
-			return 0, 0, err
+			d.broken = true
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
 
-		stmt, err := tx.Prepare("INSERT INTO " + d.table + " (Date, Tag1, Path, Tags, Version) VALUES (?, ?, ?, ?, ?)")
+		stmt, err := tx.Prepare("INSERT INTO " + d.table + " (" + driver.TaggedCodec.Columns() + ") VALUES (?, ?, ?, ?, ?)")
 		if err != nil {
-			return 0, 0, err
+			tx.Rollback()
+			d.broken = true
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
+		defer stmt.Close()
 
 		// fmt.Println("FLUSH")
 		for _, m := range d.metrics {
-			if path, tags, err := tags.TagsParse(m.Metric); err != nil {
+			if path, tags, err := d.parse(m.Metric); err != nil {
 				fmt.Fprintf(os.Stderr, "invalid metric '%s': %v", m.Metric, err)
+				result.Rejected++
 			} else {
 				// fmt.Printf("%s %+v %v\n", name, tags, m.Date)
 				for _, tag1 := range tags {
+					if !d.tag1.Emit(tag1) {
+						continue
+					}
 					if _, err := stmt.Exec(
 						clickhouse.Date(m.Date),
 						tag1,
@@ -99,23 +172,32 @@ func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
 						clickhouse.Array(tags),
 						0,
 					); err != nil {
-						return time.Since(start), 0, err
+						tx.Rollback()
+						d.broken = true
+						result.Fail()
+						result.Duration = time.Since(result.Start)
+						return result, err
 					}
 				}
-				n++
+				result.Metrics++
+				result.Rows += uint(d.tag1.Count(tags))
 			}
 		}
 
 		if err := tx.Commit(); err != nil {
-			return time.Since(start), 0, err
+			d.broken = true
+			result.Fail()
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
 
 		d.metrics = d.metrics[:0]
 		d.size = 0
 	}
-	return time.Since(start), n, nil
+	result.Duration = time.Since(result.Start)
+	return result, nil
 }
 
 func (d *TaggedDriver) Close() error {
-	return nil
+	return d.conn.Close()
 }
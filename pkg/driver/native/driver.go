@@ -7,32 +7,65 @@ import (
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
-	_ "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
 	"github.com/tevino/abool"
 )
 
+func init() {
+	driver.Register(driver.Registration{
+		Name: "native",
+		Caps: driver.CapTagged,
+		New:  newDriver,
+	})
+}
+
+func newDriver(kind driver.Capability, cfg driver.Config) (driver.Driver, error) {
+	d, err := NewTaggedDriver(cfg.DSN, cfg.Table, cfg.FlushSize)
+	if err != nil {
+		return nil, err
+	}
+	d.parse = cfg.TagsMode.Parser()
+	d.tag1 = cfg.Tag1
+	return d, nil
+}
+
 type TaggedDriver struct {
-	address []string
-	table   string
+	table string
+
+	conn   clickhouse.Conn
+	broken bool // last flush failed, check connection before next
 
 	flushSize uint // metrics max size in bytes
 
+	parse tags.ParseFunc     // tagged metric parser
+	tag1  *driver.Tag1Policy // Tag1 rows policy (nil for all tags)
+
 	size    uint                 // size (for flush detect)
 	metrics []driver.MetricIndex // metrics buffer
 
 	isRunning *abool.AtomicBool
 }
 
-func NewTaggedDriver(address, table string, flushSize uint) (*TaggedDriver, error) {
-	if len(address) == 0 {
-		address = "127.0.0.1:9000"
+func NewTaggedDriver(dsn *driver.DSN, table string, flushSize uint) (*TaggedDriver, error) {
+	opts, err := driver.ClickHouseOptions(dsn)
+	if err != nil {
+		return nil, err
 	}
+	conn, err := clickhouse.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.Ping(context.Background()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	return &TaggedDriver{
-		address:   []string{address},
 		table:     table,
+		conn:      conn,
 		flushSize: flushSize,
+		parse:     tags.TagsParse,
 		metrics: make(
 			[]driver.MetricIndex,
 			0, flushSize/100, // some evristic: size / avg metric length
@@ -44,14 +77,15 @@ func (d *TaggedDriver) Queued() uint {
 	return d.size
 }
 
-func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error) {
-	var duration time.Duration
-	var n uint
-	var err error
+func (d *TaggedDriver) Write(m driver.MetricIndex) (driver.FlushResult, error) {
+	var (
+		result driver.FlushResult
+		err    error
+	)
 	// fmt.Printf("%s %v\n", m.Metric, m.Date)
 	if d.size >= d.flushSize {
-		if duration, n, err = d.Flush(); err != nil {
-			return duration, n, err
+		if result, err = d.Flush(); err != nil {
+			return result, err
 		}
 	}
 
@@ -59,45 +93,45 @@ func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error)
 		d.metrics = append(d.metrics, m)
 		d.size += uint(len(m.Metric))
 	} else {
-		return d.Flush()
+		// end of input, keep threshold flush result
+		r, err := d.Flush()
+		result.Add(r)
+		return result, err
 	}
 
-	return duration, n, nil
+	return result, nil
 }
 
-func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
-	var n uint
-	start := time.Now()
+func (d *TaggedDriver) Flush() (driver.FlushResult, error) {
+	result := driver.FlushResult{Start: time.Now()}
 	if d.size > 0 {
 		ctx := context.Background()
-		conn, err := clickhouse.Open(&clickhouse.Options{
-			Addr: d.address,
-			Auth: clickhouse.Auth{
-				Database: "default", // TODO: parse address string and extract name
-				Username: "default",
-				Password: "",
-			},
-			//Debug:           true,
-			DialTimeout:     time.Second,
-			MaxOpenConns:    10,
-			MaxIdleConns:    5,
-			ConnMaxLifetime: time.Hour,
-		})
-		if err != nil {
-			return 0, 0, err
+		if d.broken {
+			// bad connections are dropped by pool, so ping establish a new one
+			if err := d.conn.Ping(ctx); err != nil {
+				result.Duration = time.Since(result.Start)
+				return result, err
+			}
+			d.broken = false
 		}
-		batch, err := conn.PrepareBatch(ctx, "INSERT INTO "+d.table+" (Date, Tag1, Path, Tags, Version)")
+		batch, err := d.conn.PrepareBatch(ctx, "INSERT INTO "+d.table+" ("+driver.TaggedCodec.Columns()+")")
 		if err != nil {
-			return 0, 0, err
+			d.broken = true
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
 
 		// fmt.Println("FLUSH")
 		for _, m := range d.metrics {
-			if path, tags, err := tags.TagsParse(m.Metric); err != nil {
+			if path, tags, err := d.parse(m.Metric); err != nil {
 				fmt.Fprintf(os.Stderr, "invalid metric '%s': %v", m.Metric, err)
+				result.Rejected++
 			} else {
 				// fmt.Printf("%s %+v %v\n", name, tags, m.Date)
 				for _, tag1 := range tags {
+					if !d.tag1.Emit(tag1) {
+						continue
+					}
 					if err := batch.Append(
 						m.Date,
 						tag1,
@@ -105,23 +139,31 @@ func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
 						tags,
 						uint32(0),
 					); err != nil {
-						return time.Since(start), 0, err
+						batch.Abort()
+						result.Fail()
+						result.Duration = time.Since(result.Start)
+						return result, err
 					}
 				}
-				n++
+				result.Metrics++
+				result.Rows += uint(d.tag1.Count(tags))
 			}
 		}
 
 		if err := batch.Send(); err != nil {
-			return time.Since(start), 0, err
+			d.broken = true
+			result.Fail()
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
 
 		d.metrics = d.metrics[:0]
 		d.size = 0
 	}
-	return time.Since(start), n, nil
+	result.Duration = time.Since(result.Start)
+	return result, nil
 }
 
 func (d *TaggedDriver) Close() error {
-	return nil
+	return d.conn.Close()
 }
@@ -0,0 +1,165 @@
+package nativehttp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/Native"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/driver"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+	"github.com/tevino/abool"
+)
+
+func init() {
+	driver.Register(driver.Registration{
+		Name:    "nativehttp",
+		Aliases: []string{"native-http"},
+		Caps:    driver.CapTagged,
+		New:     newDriver,
+	})
+}
+
+func newDriver(kind driver.Capability, cfg driver.Config) (driver.Driver, error) {
+	d, err := NewTaggedDriver(cfg.DSN, cfg.Table, cfg.FlushSize)
+	if err != nil {
+		return nil, err
+	}
+	d.parse = cfg.TagsMode.ReusableParser() // rows are encoded before next parse
+	d.tag1 = cfg.Tag1
+	return d, nil
+}
+
+// maxBlockRows is a max rows in Native block
+const maxBlockRows = 65536
+
+type TaggedDriver struct {
+	http *driver.HTTPInserter
+
+	flushSize uint // metrics max size in bytes
+
+	parse tags.ParseFunc     // tagged metric parser
+	tag1  *driver.Tag1Policy // Tag1 rows policy (nil for all tags)
+
+	size    uint                 // size (for flush detect)
+	metrics []driver.MetricIndex // metrics buffer
+
+	isRunning *abool.AtomicBool
+}
+
+func NewTaggedDriver(dsn *driver.DSN, table string, flushSize uint) (*TaggedDriver, error) {
+	h, err := driver.NewHTTPInserter(dsn, "INSERT INTO "+table+" ("+driver.TaggedCodec.Columns()+") FORMAT Native")
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaggedDriver{
+		http:      h,
+		flushSize: flushSize,
+		parse:     tags.ModeNatural.ReusableParser(),
+		metrics: make(
+			[]driver.MetricIndex,
+			0, flushSize/100, // some evristic: size / avg metric length
+		),
+	}, nil
+}
+
+func (d *TaggedDriver) Queued() uint {
+	return d.size
+}
+
+func (d *TaggedDriver) Write(m driver.MetricIndex) (driver.FlushResult, error) {
+	var (
+		result driver.FlushResult
+		err    error
+	)
+	// fmt.Printf("%s %v\n", m.Metric, m.Date)
+	if d.size >= d.flushSize {
+		if result, err = d.Flush(); err != nil {
+			return result, err
+		}
+	}
+
+	if len(m.Metric) > 0 {
+		d.metrics = append(d.metrics, m)
+		d.size += uint(len(m.Metric))
+	} else {
+		// end of input, keep threshold flush result
+		r, err := d.Flush()
+		result.Add(r)
+		return result, err
+	}
+
+	return result, nil
+}
+
+func (d *TaggedDriver) Flush() (driver.FlushResult, error) {
+	result := driver.FlushResult{Start: time.Now()}
+	if d.size > 0 {
+		err := d.http.Insert(&result, func(w io.Writer) error {
+			var (
+				dateCol    = Native.NewDate("Date")
+				tag1Col    = Native.NewString("Tag1")
+				pathCol    = Native.NewString("Path")
+				tagsCol    = Native.NewArrayString("Tags")
+				versionCol = Native.NewUInt32("Version")
+				block      = Native.NewBlock(dateCol, tag1Col, pathCol, tagsCol, versionCol)
+				tagsBuf    []byte
+			)
+			version := uint32(result.Start.Unix())
+			writeBlock := func() error {
+				n, err := block.WriteTo(w)
+				result.RawBytes += uint64(n)
+				block.Reset()
+				return err
+			}
+			for _, m := range d.metrics {
+				if path, tags, err := d.parse(m.Metric); err != nil {
+					fmt.Fprintf(os.Stderr, "invalid metric '%s': %v", m.Metric, err)
+					result.Rejected++
+				} else {
+					// fmt.Printf("%s %+v %v\n", name, tags, m.Date)
+					date := RowBinary.DateToUint16(m.Date)
+					tagsBuf = Native.AppendStrings(tagsBuf[:0], tags)
+					for _, tag1 := range tags {
+						if !d.tag1.Emit(tag1) {
+							continue
+						}
+						dateCol.AppendUint16(date)
+						tag1Col.Append(tag1)
+						pathCol.Append(path)
+						tagsCol.AppendEncoded(len(tags), tagsBuf)
+						versionCol.Append(version)
+					}
+					result.Metrics++
+					result.Rows += uint(d.tag1.Count(tags))
+					if dateCol.Rows() >= maxBlockRows {
+						if err := writeBlock(); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if dateCol.Rows() > 0 {
+				return writeBlock()
+			}
+			return nil
+		})
+		if err != nil {
+			result.Fail()
+			result.Duration = time.Since(result.Start)
+			return result, err
+		}
+
+		d.metrics = d.metrics[:0]
+		d.size = 0
+	}
+	result.Duration = time.Since(result.Start)
+	return result, nil
+}
+
+func (d *TaggedDriver) Close() error {
+	return d.http.Close()
+}
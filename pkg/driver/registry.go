@@ -0,0 +1,135 @@
+package driver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+)
+
+var (
+	ErrDriverNotFound     = fmt.Errorf("driver not found")
+	ErrDriverNotSupported = fmt.Errorf("table type not supported by driver")
+)
+
+// Capability is a set of graphite table types, supported by driver
+type Capability uint8
+
+const (
+	CapTagged Capability = 1 << iota // graphite tagged table
+	CapPlain                         // graphite index table (plain metrics)
+	CapPoints                        // graphite points table
+)
+
+var capabilityStrings = []string{"tagged", "plain", "points"}
+
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+func (c Capability) String() string {
+	var caps []string
+	for i, s := range capabilityStrings {
+		if c&(1<<i) != 0 {
+			caps = append(caps, s)
+		}
+	}
+	return strings.Join(caps, ",")
+}
+
+// Config is a common driver settings
+type Config struct {
+	DSN       *DSN
+	Table     string
+	FlushSize uint // metrics max size in bytes
+
+	TagsMode tags.Mode   // tagged metric path canonicalization
+	Tag1     *Tag1Policy // tags, emitted as Tag1 rows (nil for all)
+}
+
+// Factory create driver for the table type (one of capability flags)
+type Factory func(kind Capability, cfg Config) (Driver, error)
+
+// Registration describe driver, registered by driver package in init()
+type Registration struct {
+	Name    string
+	Aliases []string
+	Caps    Capability
+	New     Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Registration) // names and aliases
+	drivers    []*Registration
+)
+
+// Register make a driver available by the provided name and aliases.
+// If Register is called twice with the same name or if factory is nil, it panics.
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if r.New == nil {
+		panic("driver: Register factory is nil for " + r.Name)
+	}
+	reg := &r
+	for _, name := range append([]string{r.Name}, r.Aliases...) {
+		if _, dup := registry[name]; dup {
+			panic("driver: Register called twice for " + name)
+		}
+		registry[name] = reg
+	}
+	drivers = append(drivers, reg)
+}
+
+// Lookup return registered driver by name or alias
+func Lookup(name string) (*Registration, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	if r, ok := registry[name]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrDriverNotFound, name)
+}
+
+// Drivers return a sorted list of registered drivers names
+func Drivers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for _, r := range drivers {
+		names = append(names, r.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registrations return a list of registered drivers, sorted by name
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	regs := make([]Registration, 0, len(drivers))
+	for _, r := range drivers {
+		regs = append(regs, *r)
+	}
+	sort.Slice(regs, func(i, j int) bool { return regs[i].Name < regs[j].Name })
+	return regs
+}
+
+// New create driver by name for the table type
+func New(name string, kind Capability, cfg Config) (Driver, error) {
+	r, err := Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Caps.Has(kind) {
+		return nil, fmt.Errorf("%w: %s for %s", ErrDriverNotSupported, r.Name, kind.String())
+	}
+	return r.New(kind, cfg)
+}
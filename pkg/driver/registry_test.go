@@ -0,0 +1,48 @@
+package driver
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testDriver struct {
+	cfg Config
+}
+
+func (d *testDriver) Write(MetricIndex) (FlushResult, error) { return FlushResult{}, nil }
+func (d *testDriver) Flush() (FlushResult, error)            { return FlushResult{}, nil }
+func (d *testDriver) Close() error                           { return nil }
+func (d *testDriver) Queued() uint                           { return 0 }
+
+func TestRegistry(t *testing.T) {
+	Register(Registration{
+		Name:    "test_registry",
+		Aliases: []string{"test_registry_alias"},
+		Caps:    CapTagged | CapPoints,
+		New: func(kind Capability, cfg Config) (Driver, error) {
+			return &testDriver{cfg: cfg}, nil
+		},
+	})
+
+	r, err := Lookup("test_registry_alias")
+	assert.NoError(t, err)
+	assert.Equal(t, "test_registry", r.Name)
+	assert.Equal(t, "tagged,points", r.Caps.String())
+	assert.Contains(t, Drivers(), "test_registry")
+
+	_, err = Lookup("test_registry_not_found")
+	assert.True(t, errors.Is(err, ErrDriverNotFound))
+
+	d, err := New("test_registry", CapTagged, Config{Table: "tagged"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tagged", d.(*testDriver).cfg.Table)
+
+	_, err = New("test_registry", CapPlain, Config{Table: "index"})
+	assert.True(t, errors.Is(err, ErrDriverNotSupported))
+
+	assert.Panics(t, func() {
+		Register(Registration{Name: "test_registry_alias", New: r.New})
+	})
+}
@@ -0,0 +1,107 @@
+package driver
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Summary is a server-reported insert summary (from X-ClickHouse-Summary header)
+type Summary struct {
+	ReadRows     uint64 `json:"read_rows,string"`
+	ReadBytes    uint64 `json:"read_bytes,string"`
+	WrittenRows  uint64 `json:"written_rows,string"`
+	WrittenBytes uint64 `json:"written_bytes,string"`
+}
+
+// ParseSummary parse X-ClickHouse-Summary header value, like
+// {"read_rows":"0","read_bytes":"0","written_rows":"5","written_bytes":"410","total_rows_to_read":"0"}
+func ParseSummary(header string) (*Summary, error) {
+	if header == "" {
+		return nil, nil
+	}
+	var s Summary
+	if err := json.Unmarshal([]byte(header), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FlushResult is a result of driver Write/Flush.
+// On error metrics counters are zero (nothing is stored, metrics are kept for next flush),
+// bytes counters describe what was sent before the failure.
+type FlushResult struct {
+	Metrics  uint   // metrics written
+	Rows     uint   // rows written (one tagged metric produces one row per tag)
+	Rejected uint   // metrics rejected (invalid or not supported)
+	Bytes    uint64 // bytes on the wire (0 if not known by driver)
+	RawBytes uint64 // uncompressed bytes (0 if compression is not used or not known by driver)
+
+	Summary *Summary // server-reported summary (HTTP drivers only)
+
+	Start    time.Time
+	Duration time.Duration
+}
+
+// IsEmpty return true if nothing was flushed or rejected
+func (r *FlushResult) IsEmpty() bool {
+	return r.Metrics == 0 && r.Rejected == 0
+}
+
+// Fail reset metrics counters after failed send (metrics are kept in driver and counted on next flush)
+func (r *FlushResult) Fail() {
+	r.Metrics = 0
+	r.Rows = 0
+	r.Rejected = 0
+}
+
+// Add accumulate counters from other result (summary is not merged, Start is set from other if empty)
+func (r *FlushResult) Add(other FlushResult) {
+	if r.Start.IsZero() {
+		r.Start = other.Start
+	}
+	r.Metrics += other.Metrics
+	r.Rows += other.Rows
+	r.Rejected += other.Rejected
+	r.Bytes += other.Bytes
+	r.RawBytes += other.RawBytes
+	r.Duration += other.Duration
+}
+
+// Saved return compression savings in percents
+func (r *FlushResult) Saved() float64 {
+	if r.RawBytes == 0 {
+		return 0
+	}
+	return 100 * (float64(r.RawBytes) - float64(r.Bytes)) / float64(r.RawBytes)
+}
+
+func (r *FlushResult) String() string {
+	var sb strings.Builder
+	sb.Grow(128)
+	sb.WriteString("metrics ")
+	sb.WriteString(strconv.FormatUint(uint64(r.Metrics), 10))
+	sb.WriteString(", rows ")
+	sb.WriteString(strconv.FormatUint(uint64(r.Rows), 10))
+	sb.WriteString(", rejected ")
+	sb.WriteString(strconv.FormatUint(uint64(r.Rejected), 10))
+	if r.Bytes > 0 {
+		sb.WriteString(", bytes ")
+		sb.WriteString(strconv.FormatUint(r.Bytes, 10))
+		if r.RawBytes > 0 {
+			sb.WriteString(" (raw ")
+			sb.WriteString(strconv.FormatUint(r.RawBytes, 10))
+			sb.WriteString(", saved ")
+			sb.WriteString(strconv.FormatFloat(r.Saved(), 'f', 1, 64))
+			sb.WriteString("%)")
+		}
+	}
+	if r.Summary != nil {
+		sb.WriteString(", server written rows ")
+		sb.WriteString(strconv.FormatUint(r.Summary.WrittenRows, 10))
+		sb.WriteString(", server written bytes ")
+		sb.WriteString(strconv.FormatUint(r.Summary.WrittenBytes, 10))
+	}
+	return sb.String()
+}
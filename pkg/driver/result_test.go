@@ -0,0 +1,60 @@
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSummary(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    *Summary
+		wantErr bool
+	}{
+		{header: "", want: nil},
+		{
+			header: `{"read_rows":"0","read_bytes":"0","written_rows":"5","written_bytes":"410","total_rows_to_read":"0"}`,
+			want:   &Summary{WrittenRows: 5, WrittenBytes: 410},
+		},
+		{header: `{"written_rows":5}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := ParseSummary(tt.header)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFlushResultAdd(t *testing.T) {
+	var total FlushResult
+	total.Add(FlushResult{Metrics: 2, Rows: 6, Bytes: 100})
+	total.Add(FlushResult{Metrics: 1, Rows: 3, Rejected: 1, Bytes: 50})
+	assert.Equal(t, FlushResult{Metrics: 3, Rows: 9, Rejected: 1, Bytes: 150}, total)
+	assert.Equal(t, "metrics 3, rows 9, rejected 1, bytes 150", total.String())
+
+	start := time.Now()
+	var merged FlushResult
+	merged.Add(FlushResult{Metrics: 1, Start: start})
+	merged.Add(FlushResult{Metrics: 1, Start: start.Add(time.Second)})
+	assert.Equal(t, FlushResult{Metrics: 2, Start: start}, merged)
+
+	compressed := FlushResult{Metrics: 3, Rows: 9, Bytes: 250, RawBytes: 1000}
+	assert.Equal(t, 75.0, compressed.Saved())
+	assert.Equal(t, "metrics 3, rows 9, rejected 0, bytes 250 (raw 1000, saved 75.0%)", compressed.String())
+}
+
+func TestFlushResultFail(t *testing.T) {
+	start := time.Now()
+	r := FlushResult{Metrics: 3, Rows: 9, Rejected: 1, Bytes: 250, RawBytes: 1000, Start: start}
+	r.Fail()
+	assert.Equal(t, FlushResult{Bytes: 250, RawBytes: 1000, Start: start}, r)
+	assert.True(t, r.IsEmpty())
+}
@@ -4,11 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"net/url"
 	"os"
-	"sync/atomic"
 	"time"
 
 	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
@@ -17,34 +13,59 @@ import (
 	"github.com/tevino/abool"
 )
 
+func init() {
+	driver.Register(driver.Registration{
+		Name:    "rowbin",
+		Aliases: []string{"rowbinary"},
+		Caps:    driver.CapTagged,
+		New:     newDriver,
+	})
+}
+
+func newDriver(kind driver.Capability, cfg driver.Config) (driver.Driver, error) {
+	d, err := NewTaggedDriver(cfg.DSN, cfg.Table, cfg.FlushSize)
+	if err != nil {
+		return nil, err
+	}
+	d.parse = cfg.TagsMode.ReusableParser() // rows are encoded before next parse
+	d.tag1 = cfg.Tag1
+	return d, nil
+}
+
+// flushBufSize is a encoded rows size, written to request body at once
+const flushBufSize = 512 * 1024
+
 type TaggedDriver struct {
-	query string
+	header []byte // RowBinaryWithNamesAndTypes header
+	http   *driver.HTTPInserter
 
 	flushSize uint // metrics max size in bytes
 
+	parse tags.ParseFunc     // tagged metric parser
+	tag1  *driver.Tag1Policy // Tag1 rows policy (nil for all tags)
+
 	size    uint                 // size (for flush detect)
 	metrics []driver.MetricIndex // metrics buffer
 
 	isRunning *abool.AtomicBool
 }
 
-func NewTaggedDriver(address, table string, flushSize uint) (*TaggedDriver, error) {
-	if len(address) == 0 {
-		address = "http://127.0.0.1:8123"
+func NewTaggedDriver(dsn *driver.DSN, table string, flushSize uint) (*TaggedDriver, error) {
+	var header bytes.Buffer
+	if err := driver.TaggedCodec.WriteHeader(RowBinary.NewWriter(&header)); err != nil {
+		return nil, err
 	}
 
-	p, err := url.Parse(address)
+	h, err := driver.NewHTTPInserter(dsn, "INSERT INTO "+table+" ("+driver.TaggedCodec.Columns()+") FORMAT RowBinaryWithNamesAndTypes")
 	if err != nil {
 		return nil, err
 	}
-	q := p.Query()
-
-	q.Set("query", "INSERT INTO "+table+" (Date, Tag1, Path, Tags, Version) FORMAT RowBinary")
-	p.RawQuery = q.Encode()
 
 	return &TaggedDriver{
-		query:     p.String(),
+		header:    header.Bytes(),
+		http:      h,
 		flushSize: flushSize,
+		parse:     tags.ModeNatural.ReusableParser(),
 		metrics: make(
 			[]driver.MetricIndex,
 			0, flushSize/100, // some evristic: size / avg metric length
@@ -56,14 +77,15 @@ func (d *TaggedDriver) Queued() uint {
 	return d.size
 }
 
-func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error) {
-	var duration time.Duration
-	var n uint
-	var err error
+func (d *TaggedDriver) Write(m driver.MetricIndex) (driver.FlushResult, error) {
+	var (
+		result driver.FlushResult
+		err    error
+	)
 	// fmt.Printf("%s %v\n", m.Metric, m.Date)
 	if d.size >= d.flushSize {
-		if duration, n, err = d.Flush(); err != nil {
-			return duration, n, err
+		if result, err = d.Flush(); err != nil {
+			return result, err
 		}
 	}
 
@@ -71,79 +93,59 @@ func (d *TaggedDriver) Write(m driver.MetricIndex) (time.Duration, uint, error)
 		d.metrics = append(d.metrics, m)
 		d.size += uint(len(m.Metric))
 	} else {
-		return d.Flush()
+		// end of input, keep threshold flush result
+		r, err := d.Flush()
+		result.Add(r)
+		return result, err
 	}
 
-	return duration, n, nil
+	return result, nil
 }
 
-func (d *TaggedDriver) Flush() (time.Duration, uint, error) {
-	var sended uint32
-	start := time.Now()
+func (d *TaggedDriver) Flush() (driver.FlushResult, error) {
+	result := driver.FlushResult{Start: time.Now()}
 	if d.size > 0 {
-		pr, pw := io.Pipe()
+		err := d.http.Insert(&result, func(w io.Writer) error {
+			enc := RowBinary.GetEncoder()
+			defer RowBinary.PutEncoder(enc)
 
-		go func() {
-			defer pw.Close()
-
-			var tagsBuf bytes.Buffer
-			var buf bytes.Buffer
-			tagsBuf.Grow(4096)
-			buf.Grow(512 * 1024)
-			var n uint32
+			enc.Write(d.header)
+			version := uint32(result.Start.Unix())
 			for _, m := range d.metrics {
-				if path, tags, err := tags.TagsParse(m.Metric); err != nil {
+				if path, tags, err := d.parse(m.Metric); err != nil {
 					fmt.Fprintf(os.Stderr, "invalid metric '%s': %v", m.Metric, err)
+					result.Rejected++
 				} else {
 					// fmt.Printf("%s %+v %v\n", name, tags, m.Date)
-					tagsBuf.Reset()
-					buf.Reset()
-					RowBinary.NewWriter(&tagsBuf).WriteStringList(tags)
-					w := RowBinary.NewWriter(&buf)
-					for _, tag1 := range tags {
-						// Date, Tag1, Path, Tags, Version
-						w.WriteDate(m.Date)
-						w.WriteString(tag1)
-						w.WriteString(path)
-						w.Write(tagsBuf.Bytes())
-						w.WriteUint32(uint32(start.Unix()))
-					}
-					if _, err := pw.Write(buf.Bytes()); err != nil {
-						break
+					n := enc.Len()
+					rows := driver.EncodeTagged(enc, m.Date, path, tags, version, d.tag1)
+					result.Metrics++
+					result.Rows += uint(rows)
+					result.RawBytes += uint64(enc.Len() - n)
+					if enc.Len() >= flushBufSize {
+						if _, err := enc.WriteTo(w); err != nil {
+							return err
+						}
 					}
-					n++
 				}
 			}
-			atomic.AddUint32(&sended, n)
-		}()
-
-		req, err := http.NewRequest("POST", d.query, pr)
-		if err != nil {
-			return 0, 0, err
-		}
-
-		client := &http.Client{
-			Timeout:   time.Second * 60,
-			Transport: &http.Transport{DisableKeepAlives: true},
-		}
-		resp, err := client.Do(req)
+			result.RawBytes += uint64(len(d.header))
+			_, err := enc.WriteTo(w)
+			return err
+		})
 		if err != nil {
-			return time.Since(start), uint(atomic.LoadUint32(&sended)), err
-		}
-		defer resp.Body.Close()
-
-		body, _ := ioutil.ReadAll(resp.Body)
-
-		if resp.StatusCode != 200 {
-			return time.Since(start), uint(atomic.LoadUint32(&sended)), fmt.Errorf("clickhouse response status %d: %s", resp.StatusCode, string(body))
+			result.Fail()
+			result.Duration = time.Since(result.Start)
+			return result, err
 		}
 
 		d.metrics = d.metrics[:0]
 		d.size = 0
 	}
-	return time.Since(start), uint(atomic.LoadUint32(&sended)), nil
+	result.Duration = time.Since(result.Start)
+	return result, nil
 }
 
 func (d *TaggedDriver) Close() error {
-	return nil
+	return d.http.Close()
 }
@@ -0,0 +1,64 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+)
+
+// TaggedRow is a graphite tagged table row (one row per metric tag)
+type TaggedRow struct {
+	Date    time.Time `ch:"Date,Date"`
+	Tag1    string    `ch:"Tag1,String"`
+	Path    string    `ch:"Path,String"`
+	Tags    []string  `ch:"Tags,Array(String)"`
+	Version uint32    `ch:"Version,UInt32"`
+}
+
+// IndexRow is a graphite index table row (plain metrics)
+type IndexRow struct {
+	Date    time.Time `ch:"Date,Date"`
+	Level   uint32    `ch:"Level,UInt32"`
+	Path    string    `ch:"Path,String"`
+	Version uint32    `ch:"Version,UInt32"`
+}
+
+// PointsRow is a graphite points table row
+type PointsRow struct {
+	Path      string    `ch:"Path,String"`
+	Value     float64   `ch:"Value,Float64"`
+	Time      uint32    `ch:"Time,UInt32"`
+	Date      time.Time `ch:"Date,Date"`
+	Timestamp uint32    `ch:"Timestamp,UInt32"`
+}
+
+// Row layouts
+var (
+	TaggedCodec = RowBinary.MustCodecOf(TaggedRow{})
+	IndexCodec  = RowBinary.MustCodecOf(IndexRow{})
+	PointsCodec = RowBinary.MustCodecOf(PointsRow{})
+)
+
+// EncodeTagged append TaggedRow rows (one per tag, emitted by Tag1 policy) for metric, tags list is encoded once.
+// Return rows count.
+func EncodeTagged(e *RowBinary.Encoder, date time.Time, path string, tags []string, version uint32, tag1Policy *Tag1Policy) int {
+	var start, end, rows int
+	for _, tag1 := range tags {
+		if !tag1Policy.Emit(tag1) {
+			continue
+		}
+		e.WriteDate(date)
+		e.WriteString(tag1)
+		e.WriteString(path)
+		if rows == 0 {
+			start = e.Len()
+			e.WriteStringList(tags)
+			end = e.Len()
+		} else {
+			e.Write(e.Bytes()[start:end])
+		}
+		e.WriteUint32(version)
+		rows++
+	}
+	return rows
+}
@@ -0,0 +1,89 @@
+package driver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowsLayout(t *testing.T) {
+	assert.Equal(t, "Date, Tag1, Path, Tags, Version", TaggedCodec.Columns())
+	assert.Equal(t, []string{"Date", "String", "String", "Array(String)", "UInt32"}, TaggedCodec.Types())
+	assert.Equal(t, "Date, Level, Path, Version", IndexCodec.Columns())
+	assert.Equal(t, "Path, Value, Time, Date, Timestamp", PointsCodec.Columns())
+}
+
+func TestTaggedRowMarshal(t *testing.T) {
+	row := TaggedRow{
+		Date:    time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC),
+		Tag1:    "__name__=test",
+		Path:    "test?a=1",
+		Tags:    []string{"__name__=test", "a=1"},
+		Version: 1612325106,
+	}
+
+	// hand-written encoding
+	var want bytes.Buffer
+	w := RowBinary.NewWriter(&want)
+	w.WriteDate(row.Date)
+	w.WriteString(row.Tag1)
+	w.WriteString(row.Path)
+	w.WriteStringList(row.Tags)
+	w.WriteUint32(row.Version)
+
+	var got bytes.Buffer
+	require.NoError(t, TaggedCodec.Marshal(RowBinary.NewWriter(&got), &row))
+	assert.Equal(t, want.Bytes(), got.Bytes())
+
+	var decoded TaggedRow
+	require.NoError(t, TaggedCodec.Unmarshal(RowBinary.NewReaderBuffered(&got, 0), &decoded))
+	assert.Equal(t, row, decoded)
+}
+
+func TestEncodeTagged(t *testing.T) {
+	date := time.Date(2021, 2, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		path     string
+		tags     []string
+		keys     []string // Tag1 policy keys
+		wantTag1 []string // nil for all tags
+	}{
+		{path: "test", tags: []string{}},
+		{path: "test?a=1", tags: []string{"__name__=test", "a=1"}},
+		{path: "test?a=1&b=2", tags: []string{"__name__=test", "a=1", "b=2"}},
+		{
+			path: "test?a=1&b=2", tags: []string{"__name__=test", "a=1", "b=2"},
+			keys: []string{"b"}, wantTag1: []string{"__name__=test", "b=2"},
+		},
+		{
+			path: "test?A=1&b=2", tags: []string{"A=1", "__name__=test", "b=2"},
+			keys: []string{"__name__"}, wantTag1: []string{"__name__=test"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+"#"+strings.Join(tt.keys, ","), func(t *testing.T) {
+			wantTag1 := tt.wantTag1
+			if wantTag1 == nil {
+				wantTag1 = tt.tags
+			}
+			var want bytes.Buffer
+			w := RowBinary.NewWriter(&want)
+			for _, tag1 := range wantTag1 {
+				row := TaggedRow{Date: date, Tag1: tag1, Path: tt.path, Tags: tt.tags, Version: 10}
+				require.NoError(t, TaggedCodec.Marshal(w, &row))
+			}
+
+			// small buffer for check reallocation
+			e := RowBinary.NewEncoder(1)
+			rows := EncodeTagged(e, date, tt.path, tt.tags, 10, NewTag1Policy(tt.keys))
+			require.NoError(t, e.Err())
+			assert.Equal(t, len(wantTag1), rows)
+			assert.Equal(t, want.String(), string(e.Bytes()))
+		})
+	}
+}
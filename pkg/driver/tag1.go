@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"sort"
+	"strings"
+)
+
+const nameKey = "__name__"
+
+// Tag1Policy select tags, emitted as Tag1 rows in tagged table.
+// __name__ is always emitted, other tags only for allowed keys, nil policy emit all tags.
+// Metrics stay queryable by seriesByTag with name or allowed key in first expression.
+type Tag1Policy struct {
+	keys map[string]struct{}
+}
+
+// NewTag1Policy create policy with allowed tag keys (nil for empty keys, all tags emitted).
+// Use __name__ as single key for emit only name rows.
+func NewTag1Policy(keys []string) *Tag1Policy {
+	if len(keys) == 0 {
+		return nil
+	}
+	p := &Tag1Policy{keys: make(map[string]struct{}, len(keys))}
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" && key != nameKey {
+			p.keys[key] = struct{}{}
+		}
+	}
+	return p
+}
+
+// Emit check if tag (k=v) emitted as Tag1 row
+func (p *Tag1Policy) Emit(tag string) bool {
+	if p == nil {
+		return true
+	}
+	key := tag
+	if n := strings.IndexByte(tag, '='); n != -1 {
+		key = tag[:n]
+	}
+	if key == nameKey {
+		return true
+	}
+	_, ok := p.keys[key]
+	return ok
+}
+
+// Count return emitted Tag1 rows count for tags list
+func (p *Tag1Policy) Count(tags []string) int {
+	if p == nil {
+		return len(tags)
+	}
+	n := 0
+	for _, tag := range tags {
+		if p.Emit(tag) {
+			n++
+		}
+	}
+	return n
+}
+
+// String return allowed keys (with __name__), empty for all tags
+func (p *Tag1Policy) String() string {
+	if p == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(p.keys)+1)
+	for key := range p.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(append([]string{nameKey}, keys...), ",")
+}
@@ -0,0 +1,36 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTag1Policy(t *testing.T) {
+	tags := []string{"__name__=cpu", "dc=qwe", "env=prod", "host=h1"}
+	tests := []struct {
+		keys     []string
+		want     []string
+		wantKeys string
+	}{
+		{keys: nil, want: tags},
+		{keys: []string{}, want: tags},
+		{keys: []string{"__name__"}, want: []string{"__name__=cpu"}, wantKeys: "__name__"},
+		{keys: []string{"host", " dc ", ""}, want: []string{"__name__=cpu", "dc=qwe", "host=h1"}, wantKeys: "__name__,dc,host"},
+		{keys: []string{"missing"}, want: []string{"__name__=cpu"}, wantKeys: "__name__,missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantKeys, func(t *testing.T) {
+			p := NewTag1Policy(tt.keys)
+			var got []string
+			for _, tag := range tags {
+				if p.Emit(tag) {
+					got = append(got, tag)
+				}
+			}
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, len(tt.want), p.Count(tags))
+			assert.Equal(t, tt.wantKeys, p.String())
+		})
+	}
+}
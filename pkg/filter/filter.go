@@ -0,0 +1,133 @@
+package filter
+
+import (
+	"strings"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+)
+
+// Filter select metrics by include and exclude patterns:
+// graphite globs (cpu.*.load{1,5}) for plain metrics and tagged metrics names and
+// seriesByTag expressions (seriesByTag('name=cpu','env!=test') or name=cpu) for tagged metrics
+// and plain metrics (as metrics with name tag only).
+// Metric is passed, if it match any include pattern (or include patterns not set) and don't match exclude patterns.
+type Filter struct {
+	include    patterns
+	exclude    patterns
+	hasInclude bool
+
+	excluded    uint64 // metrics, matched exclude patterns
+	notIncluded uint64 // metrics, not matched include patterns
+}
+
+// Stats is a filtered metrics counters
+type Stats struct {
+	Excluded    uint64
+	NotIncluded uint64
+}
+
+// Filtered return filtered metrics count
+func (s Stats) Filtered() uint64 {
+	return s.Excluded + s.NotIncluded
+}
+
+type patterns struct {
+	globs  []*tags.Glob
+	series []tags.SeriesByTag
+}
+
+// isTagged detect seriesByTag pattern
+func isTagged(pattern string) bool {
+	return strings.HasPrefix(pattern, "seriesByTag(") || strings.ContainsAny(pattern, "='\"")
+}
+
+func (p *patterns) add(pattern string) error {
+	if isTagged(pattern) {
+		s, err := tags.ParseSeriesByTag(pattern)
+		if err != nil {
+			return err
+		}
+		p.series = append(p.series, s)
+	} else {
+		g, err := tags.CompileGlob(pattern)
+		if err != nil {
+			return err
+		}
+		p.globs = append(p.globs, g)
+	}
+	return nil
+}
+
+func (p *patterns) match(metric string) (bool, error) {
+	name := metric
+	if n := strings.IndexByte(metric, ';'); n != -1 {
+		name = metric[:n]
+	}
+	for _, g := range p.globs {
+		if g.Match(name) {
+			return true, nil
+		}
+	}
+	if len(p.series) == 0 {
+		return false, nil
+	}
+	var list []string
+	if len(name) == len(metric) {
+		list = []string{"__name__=" + metric}
+	} else {
+		var err error
+		if _, list, err = tags.TagsParse(metric); err != nil {
+			return false, err
+		}
+	}
+	for _, s := range p.series {
+		if s.Match(list) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// New create filter from include and exclude patterns
+func New(include, exclude []string) (*Filter, error) {
+	f := &Filter{hasInclude: len(include) > 0}
+	for _, pattern := range include {
+		if err := f.include.add(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, pattern := range exclude {
+		if err := f.exclude.add(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Match report whether metric is passed by filter (error returned for invalid tagged metric)
+func (f *Filter) Match(metric string) (bool, error) {
+	if f.hasInclude {
+		ok, err := f.include.match(metric)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			f.notIncluded++
+			return false, nil
+		}
+	}
+	ok, err := f.exclude.match(metric)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		f.excluded++
+		return false, nil
+	}
+	return true, nil
+}
+
+// Stats return filtered metrics counters
+func (f *Filter) Stats() Stats {
+	return Stats{Excluded: f.excluded, NotIncluded: f.notIncluded}
+}
@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		metrics map[string]bool
+		want    Stats
+	}{
+		{
+			name: "empty",
+			metrics: map[string]bool{
+				"cpu.h1.load1":  true,
+				"cpu;env=prod":  true,
+				"cpu;env=test":  true,
+				"mem.h1.used":   true,
+				"mem;host=dc1a": true,
+			},
+		},
+		{
+			name:    "include",
+			include: []string{"cpu.*.load{1,5}", "seriesByTag('name=cpu', 'env!=test')"},
+			metrics: map[string]bool{
+				"cpu.h1.load1":  true,
+				"cpu.h1.load15": false,
+				"cpu;env=prod":  true,
+				"cpu;env=test":  false,
+				"mem;host=dc1a": false,
+			},
+			want: Stats{NotIncluded: 3},
+		},
+		{
+			name:    "exclude",
+			exclude: []string{"mem.*.*", "host=~dc1"},
+			metrics: map[string]bool{
+				"cpu.h1.load1":  true,
+				"mem.h1.used":   false,
+				"cpu;env=prod":  true,
+				"mem;host=dc1a": false,
+				"mem;host=dc2a": true,
+			},
+			want: Stats{Excluded: 2},
+		},
+		{
+			name:    "include and exclude",
+			include: []string{"cpu.*.*", "name=~cpu|mem"},
+			exclude: []string{"*.h2.*", "env=test"},
+			metrics: map[string]bool{
+				"cpu.h1.load1":  true,
+				"cpu.h2.load1":  false,
+				"mem.h1.used":   true, // plain metric matched by name expression
+				"disk.h1.used":  false,
+				"cpu;env=prod":  true,
+				"cpu;env=test":  false,
+				"mem;host=dc1a": true,
+				"disk;dc=a":     false,
+			},
+			want: Stats{Excluded: 2, NotIncluded: 2},
+		},
+		{
+			name:    "globs only",
+			include: []string{"cpu.*", "mem"},
+			metrics: map[string]bool{
+				"cpu.h1":        true,
+				"cpu.h1;env=a":  true,
+				"mem;host=dc1a": true,
+				"mem.used;a=b":  false,
+				"disk;dc=a":     false,
+			},
+			want: Stats{NotIncluded: 2},
+		},
+		{
+			name:    "series only",
+			include: []string{"name=~cpu", "env=prod"},
+			metrics: map[string]bool{
+				"cpu.h1":       true,
+				"cpu;env=test": true,
+				"mem;env=prod": true,
+				"mem.h1":       false,
+				"mem;env=test": false,
+			},
+			want: Stats{NotIncluded: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.include, tt.exclude)
+			require.NoError(t, err)
+			for metric, want := range tt.metrics {
+				got, err := f.Match(metric)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, metric)
+			}
+			assert.Equal(t, tt.want, f.Stats())
+			assert.Equal(t, tt.want.Excluded+tt.want.NotIncluded, f.Stats().Filtered())
+		})
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	_, err := New([]string{"cpu.{a,b"}, nil)
+	assert.Error(t, err)
+	_, err = New(nil, []string{"seriesByTag('env!=test')"})
+	assert.Error(t, err)
+
+	f, err := New([]string{"name=cpu"}, nil)
+	require.NoError(t, err)
+	_, err = f.Match("cpu;env")
+	assert.Error(t, err)
+}
@@ -0,0 +1,174 @@
+package limits
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/RowBinary"
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+)
+
+// Limit names
+const (
+	LimitTags        = "max_tags"
+	LimitKeyLength   = "max_key_length"
+	LimitValueLength = "max_value_length"
+	LimitValues      = "max_values"
+	LimitMetricLen   = "max_metric_length"
+	LimitInvalid     = "invalid"
+)
+
+// Config is a tagged metrics limits (0 for unlimited)
+type Config struct {
+	MaxTags        int // max tags per metric (without name)
+	MaxKeyLength   int // max tag key length
+	MaxValueLength int // max tag value length
+	MaxValues      int // max distinct values per tag key per date
+	MaxMetricLen   int // max metric length
+}
+
+// Enabled report whether any limit is set
+func (c Config) Enabled() bool {
+	return c.MaxTags > 0 || c.MaxKeyLength > 0 || c.MaxValueLength > 0 || c.MaxValues > 0 || c.MaxMetricLen > 0
+}
+
+// Error describe limit violation
+type Error struct {
+	Limit string
+	Key   string // tag key (empty for metric limits)
+	Value int    // actual value
+	Max   int
+}
+
+func (e *Error) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s exceeded: %d > %d", e.Limit, e.Value, e.Max)
+	}
+	return fmt.Sprintf("%s exceeded for key '%s': %d > %d", e.Limit, e.Key, e.Value, e.Max)
+}
+
+// Violation is a limit violations counter for tag key
+type Violation struct {
+	Limit string
+	Key   string
+	Count uint64
+}
+
+type dateKey struct {
+	date uint16
+	key  string
+}
+
+type violationKey struct {
+	limit string
+	key   string
+}
+
+// Guard check tagged metrics limits (not safe for concurrent use)
+type Guard struct {
+	cfg        Config
+	values     map[dateKey]map[string]struct{}
+	violations map[violationKey]uint64
+	rejected   uint64
+}
+
+// New create guard
+func New(cfg Config) *Guard {
+	return &Guard{
+		cfg:        cfg,
+		values:     make(map[dateKey]map[string]struct{}),
+		violations: make(map[violationKey]uint64),
+	}
+}
+
+func (g *Guard) reject(err error) error {
+	g.rejected++
+	if e, ok := err.(*Error); ok {
+		g.violations[violationKey{limit: e.Limit, key: e.Key}]++
+	} else {
+		g.violations[violationKey{limit: LimitInvalid}]++
+	}
+	return err
+}
+
+// Check check tagged metric limits, distinct values are recorded only for accepted metrics
+func (g *Guard) Check(metric string, date time.Time) error {
+	if g.cfg.MaxMetricLen > 0 && len(metric) > g.cfg.MaxMetricLen {
+		return g.reject(&Error{Limit: LimitMetricLen, Value: len(metric), Max: g.cfg.MaxMetricLen})
+	}
+	m, err := tags.ParseMetric(metric)
+	if err != nil {
+		return g.reject(err)
+	}
+	if g.cfg.MaxTags > 0 && len(m.Tags) > g.cfg.MaxTags {
+		return g.reject(&Error{Limit: LimitTags, Value: len(m.Tags), Max: g.cfg.MaxTags})
+	}
+	d := RowBinary.DateToUint16(date)
+	for _, tag := range m.Tags {
+		if g.cfg.MaxKeyLength > 0 && len(tag.Key) > g.cfg.MaxKeyLength {
+			return g.reject(&Error{Limit: LimitKeyLength, Key: tag.Key, Value: len(tag.Key), Max: g.cfg.MaxKeyLength})
+		}
+		if g.cfg.MaxValueLength > 0 && len(tag.Value) > g.cfg.MaxValueLength {
+			return g.reject(&Error{Limit: LimitValueLength, Key: tag.Key, Value: len(tag.Value), Max: g.cfg.MaxValueLength})
+		}
+		if g.cfg.MaxValues > 0 {
+			values := g.values[dateKey{date: d, key: tag.Key}]
+			if _, ok := values[tag.Value]; !ok && len(values) >= g.cfg.MaxValues {
+				return g.reject(&Error{Limit: LimitValues, Key: tag.Key, Value: len(values) + 1, Max: g.cfg.MaxValues})
+			}
+		}
+	}
+	if g.cfg.MaxValues > 0 {
+		for _, tag := range m.Tags {
+			k := dateKey{date: d, key: tag.Key}
+			values, ok := g.values[k]
+			if !ok {
+				values = make(map[string]struct{})
+				g.values[k] = values
+			}
+			values[tag.Value] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// Rejected return rejected metrics count
+func (g *Guard) Rejected() uint64 {
+	return g.rejected
+}
+
+// Violations return violations counters, sorted by count desc
+func (g *Guard) Violations() []Violation {
+	list := make([]Violation, 0, len(g.violations))
+	for k, n := range g.violations {
+		list = append(list, Violation{Limit: k.limit, Key: k.key, Count: n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		if list[i].Limit != list[j].Limit {
+			return list[i].Limit < list[j].Limit
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// WriteSummary write violations summary
+func (g *Guard) WriteSummary(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "rejected by limits: %d\n", g.rejected)
+	for _, v := range g.Violations() {
+		if v.Key == "" {
+			fmt.Fprintf(&sb, "  %-18s %10d\n", v.Limit, v.Count)
+		} else {
+			fmt.Fprintf(&sb, "  %-18s %10d key '%s'\n", v.Limit, v.Count, v.Key)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
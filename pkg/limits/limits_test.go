@@ -0,0 +1,87 @@
+package limits
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardCheck(t *testing.T) {
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		cfg     Config
+		metric  string
+		wantErr *Error
+	}{
+		{name: "unlimited", metric: "cpu;env=prod;host=h1"},
+		{
+			name: "metric length", cfg: Config{MaxMetricLen: 10}, metric: "cpu;env=prod",
+			wantErr: &Error{Limit: LimitMetricLen, Value: 12, Max: 10},
+		},
+		{
+			name: "tags", cfg: Config{MaxTags: 1}, metric: "cpu;env=prod;host=h1",
+			wantErr: &Error{Limit: LimitTags, Value: 2, Max: 1},
+		},
+		{name: "tags in limit", cfg: Config{MaxTags: 2}, metric: "cpu;env=prod;host=h1"},
+		{
+			name: "key length", cfg: Config{MaxKeyLength: 3}, metric: "cpu;env=prod;host=h1",
+			wantErr: &Error{Limit: LimitKeyLength, Key: "host", Value: 4, Max: 3},
+		},
+		{
+			name: "value length", cfg: Config{MaxValueLength: 3}, metric: "cpu;env=prod;host=h1",
+			wantErr: &Error{Limit: LimitValueLength, Key: "env", Value: 4, Max: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(tt.cfg)
+			err := g.Check(tt.metric, date)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, uint64(0), g.Rejected())
+			} else {
+				assert.Equal(t, tt.wantErr, err)
+				assert.Equal(t, uint64(1), g.Rejected())
+				assert.Equal(t, []Violation{{Limit: tt.wantErr.Limit, Key: tt.wantErr.Key, Count: 1}}, g.Violations())
+			}
+		})
+	}
+}
+
+func TestGuardValues(t *testing.T) {
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+	g := New(Config{MaxValues: 2})
+
+	assert.NoError(t, g.Check("req;id=1;host=h1", date))
+	assert.NoError(t, g.Check("req;id=2;host=h1", date))
+	assert.NoError(t, g.Check("req;id=1;host=h2", date))
+	assert.Equal(t, &Error{Limit: LimitValues, Key: "id", Value: 3, Max: 2}, g.Check("req;id=3;host=h1", date))
+	assert.Equal(t, &Error{Limit: LimitValues, Key: "id", Value: 3, Max: 2}, g.Check("req;id=4;host=h1", date))
+	// rejected metric values are not recorded
+	assert.Equal(t, &Error{Limit: LimitValues, Key: "host", Value: 3, Max: 2}, g.Check("req;id=2;host=h3", date))
+	assert.NoError(t, g.Check("req;id=2;host=h2", date))
+	// limit is per date
+	assert.NoError(t, g.Check("req;id=3;host=h3", date.AddDate(0, 0, 1)))
+	assert.Error(t, g.Check("req;id", date))
+
+	assert.Equal(t, uint64(4), g.Rejected())
+	assert.Equal(t, []Violation{
+		{Limit: LimitValues, Key: "id", Count: 2},
+		{Limit: LimitInvalid, Count: 1},
+		{Limit: LimitValues, Key: "host", Count: 1},
+	}, g.Violations())
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteSummary(&buf))
+	assert.Equal(t,
+		"rejected by limits: 4\n"+
+			"  max_values                  2 key 'id'\n"+
+			"  invalid                     1\n"+
+			"  max_values                  1 key 'host'\n",
+		buf.String(),
+	)
+}
@@ -0,0 +1,246 @@
+package rewrite
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule actions
+const (
+	ActionRenameKey    = "rename_key"    // rename tag key to To
+	ActionDrop         = "drop"          // drop tags by Key or by key Regex
+	ActionAdd          = "add"           // add (or replace) static Tags
+	ActionRewriteValue = "rewrite_value" // rewrite Key value with Regex and Replace (with $1 captures)
+	ActionRenameName   = "rename_name"   // rewrite metric name with Regex and Replace (with $1 captures)
+)
+
+// RuleConfig is a rewrite rule from config file, like
+//
+//	rules:
+//	  - action: rename_key
+//	    key: instance
+//	    to: host
+//	  - action: drop
+//	    regex: ^tmp_
+//	  - action: add
+//	    name: ^cpu\.
+//	    tags: { env: prod }
+//	  - action: rewrite_value
+//	    key: host
+//	    regex: ^(.+)\.example\.com$
+//	    replace: $1
+//	  - action: rename_name
+//	    regex: ^old\.(.*)
+//	    replace: new.$1
+type RuleConfig struct {
+	Action  string            `yaml:"action"`
+	Name    string            `yaml:"name"` // optional metric name regex, rule is applied only for matched metrics
+	Key     string            `yaml:"key"`
+	To      string            `yaml:"to"`
+	Regex   string            `yaml:"regex"`
+	Replace string            `yaml:"replace"`
+	Tags    map[string]string `yaml:"tags"`
+}
+
+// Config is a rewrite rules config file
+type Config struct {
+	Rules []RuleConfig `yaml:"rules"`
+}
+
+type rule struct {
+	name  *regexp.Regexp // metric name filter
+	apply func(m *tags.Metric)
+}
+
+// Rules is a compiled rewrite rules pipeline
+type Rules struct {
+	rules []rule
+}
+
+// New compile rules
+func New(cfg Config) (*Rules, error) {
+	r := &Rules{rules: make([]rule, 0, len(cfg.Rules))}
+	for i, rc := range cfg.Rules {
+		rl, err := compile(rc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i+1, rc.Action, err)
+		}
+		r.rules = append(r.rules, rl)
+	}
+	return r, nil
+}
+
+// Parse parse and compile YAML rules
+func Parse(data []byte) (*Rules, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return New(cfg)
+}
+
+// Load load rules from YAML file
+func Load(filename string) (*Rules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return r, nil
+}
+
+func compileRegex(rc RuleConfig) (*regexp.Regexp, error) {
+	if rc.Regex == "" {
+		return nil, fmt.Errorf("regex not set")
+	}
+	return regexp.Compile(rc.Regex)
+}
+
+func compile(rc RuleConfig) (rule, error) {
+	var (
+		rl  rule
+		err error
+	)
+	if rc.Name != "" {
+		if rl.name, err = regexp.Compile(rc.Name); err != nil {
+			return rl, err
+		}
+	}
+	switch rc.Action {
+	case ActionRenameKey:
+		if rc.Key == "" || rc.To == "" {
+			return rl, fmt.Errorf("key and to must be set")
+		}
+		if err = tags.ValidateKey(rc.To); err != nil {
+			return rl, fmt.Errorf("%w: '%s'", err, rc.To)
+		}
+		if rc.To == "__name__" {
+			return rl, fmt.Errorf("%w: '%s'", tags.ErrReservedKey, rc.To)
+		}
+		key, to := rc.Key, rc.To
+		rl.apply = func(m *tags.Metric) {
+			value, ok := m.Get(key)
+			if !ok {
+				return
+			}
+			m.DeleteFunc(func(tag tags.Tag) bool { return tag.Key == key })
+			m.Set(to, value)
+		}
+	case ActionDrop:
+		switch {
+		case rc.Key != "" && rc.Regex == "":
+			key := rc.Key
+			rl.apply = func(m *tags.Metric) {
+				m.DeleteFunc(func(tag tags.Tag) bool { return tag.Key == key })
+			}
+		case rc.Key == "" && rc.Regex != "":
+			re, err := compileRegex(rc)
+			if err != nil {
+				return rl, err
+			}
+			rl.apply = func(m *tags.Metric) {
+				m.DeleteFunc(func(tag tags.Tag) bool { return re.MatchString(tag.Key) })
+			}
+		default:
+			return rl, fmt.Errorf("one of key or regex must be set")
+		}
+	case ActionAdd:
+		if len(rc.Tags) == 0 {
+			return rl, fmt.Errorf("tags not set")
+		}
+		add := make([]tags.Tag, 0, len(rc.Tags))
+		for k, v := range rc.Tags {
+			if err = tags.ValidateKey(k); err != nil {
+				return rl, fmt.Errorf("%w: '%s'", err, k)
+			}
+			if err = tags.ValidateValue(v); err != nil {
+				return rl, fmt.Errorf("%w: '%s'", err, v)
+			}
+			add = append(add, tags.Tag{Key: k, Value: v})
+		}
+		// map order is random, so add tags in stable order
+		sort.Slice(add, func(i, j int) bool { return add[i].Key < add[j].Key })
+		rl.apply = func(m *tags.Metric) {
+			for _, tag := range add {
+				m.Set(tag.Key, tag.Value)
+			}
+		}
+	case ActionRewriteValue:
+		if rc.Key == "" {
+			return rl, fmt.Errorf("key not set")
+		}
+		re, err := compileRegex(rc)
+		if err != nil {
+			return rl, err
+		}
+		key, replace := rc.Key, rc.Replace
+		rl.apply = func(m *tags.Metric) {
+			for i := range m.Tags {
+				if m.Tags[i].Key == key {
+					m.Tags[i].Value = re.ReplaceAllString(m.Tags[i].Value, replace)
+				}
+			}
+		}
+	case ActionRenameName:
+		re, err := compileRegex(rc)
+		if err != nil {
+			return rl, err
+		}
+		replace := rc.Replace
+		rl.apply = func(m *tags.Metric) {
+			m.Name = re.ReplaceAllString(m.Name, replace)
+		}
+	default:
+		return rl, fmt.Errorf("unknown action")
+	}
+	return rl, nil
+}
+
+// Len return rules count
+func (r *Rules) Len() int {
+	return len(r.rules)
+}
+
+// ApplyMetric apply rules to parsed metric
+func (r *Rules) ApplyMetric(m *tags.Metric) {
+	for _, rl := range r.rules {
+		if rl.name == nil || rl.name.MatchString(m.Name) {
+			rl.apply(m)
+		}
+	}
+}
+
+// Apply apply rules to metric (name;k1=v1;k2=v2 or plain name).
+// Tagged metric without tags after rewrite is returned as plain name.
+// Rewritten name and tags are validated (rename_name and rewrite_value results are not known before apply).
+func (r *Rules) Apply(metric string) (string, error) {
+	if len(r.rules) == 0 {
+		return metric, nil
+	}
+	m, err := tags.ParseMetric(metric)
+	if err != nil {
+		return metric, err
+	}
+	r.ApplyMetric(&m)
+	if err = tags.ValidateName(m.Name); err != nil {
+		return metric, fmt.Errorf("%w '%s' after rewrite of '%s'", err, m.Name, metric)
+	}
+	rewritten := m.String()
+	if len(m.Tags) > 0 {
+		if err = tags.Validate(rewritten); err != nil {
+			return metric, fmt.Errorf("rewrite of '%s': %w", metric, err)
+		}
+	}
+	return rewritten, nil
+}
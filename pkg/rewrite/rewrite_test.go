@@ -0,0 +1,131 @@
+package rewrite
+
+import (
+	"testing"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRules = `
+rules:
+  - action: rename_key
+    key: instance
+    to: host
+  - action: drop
+    key: job
+  - action: drop
+    regex: ^tmp_
+  - action: add
+    name: ^cpu\.
+    tags: { env: prod, dc: eu }
+  - action: rewrite_value
+    key: host
+    regex: ^(.+)\.example\.com(:\d+)?$
+    replace: $1
+  - action: rename_name
+    regex: ^old\.(.*)
+    replace: new.$1
+`
+
+func TestRulesApply(t *testing.T) {
+	r, err := Parse([]byte(testRules))
+	require.NoError(t, err)
+	assert.Equal(t, 6, r.Len())
+
+	tests := []struct {
+		metric string
+		want   string
+	}{
+		{metric: "cpu.load;instance=h1.example.com:9100;job=node", want: "cpu.load;host=h1;dc=eu;env=prod"},
+		{metric: "cpu.load;env=dev;tmp_id=1;tmp_x=2", want: "cpu.load;env=prod;dc=eu"},
+		{metric: "mem.used;host=h2.example.org;job=node", want: "mem.used;host=h2.example.org"},
+		{metric: "mem.used;job=node", want: "mem.used"},
+		{metric: "old.mem.used;host=h1.example.com", want: "new.mem.used;host=h1"},
+		{metric: "old.mem.used", want: "new.mem.used"},
+		{metric: "cpu.load", want: "cpu.load;dc=eu;env=prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			got, err := r.Apply(tt.metric)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = r.Apply("mem.used;job")
+	assert.Error(t, err)
+}
+
+func TestRulesApplyInvalid(t *testing.T) {
+	r, err := Parse([]byte(`
+rules:
+  - action: rename_name
+    regex: ^bad\.(.*)
+    replace: $1
+  - action: rename_name
+    regex: ^semi\.(.*)
+    replace: $1;dc
+  - action: rewrite_value
+    key: host
+    regex: ^(.*)\.example\.com$
+    replace: $1
+`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		metric  string
+		want    string
+		wantErr error
+	}{
+		{metric: "bad.cpu;host=h1.example.com", want: "cpu;host=h1"},
+		{metric: "semi.cpu;host=h1", wantErr: tags.ErrInvalidName},
+		{metric: "bad.cpu=1", wantErr: tags.ErrInvalidName},
+		{metric: "bad.", wantErr: tags.ErrEmptyName},
+		{metric: "cpu;host=.example.com", wantErr: tags.ErrEmptyValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			got, err := r.Apply(tt.metric)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, tt.metric, got)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{name: "unknown action", rules: "rules:\n  - action: copy\n"},
+		{name: "unknown field", rules: "rules:\n  - action: drop\n    keys: job\n"},
+		{name: "rename_key without to", rules: "rules:\n  - action: rename_key\n    key: job\n"},
+		{name: "rename_key empty to", rules: "rules:\n  - action: rename_key\n    key: job\n    to: ''\n"},
+		{name: "rename_key invalid to", rules: "rules:\n  - action: rename_key\n    key: job\n    to: 'a;b'\n"},
+		{name: "rename_key to with =", rules: "rules:\n  - action: rename_key\n    key: job\n    to: a=b\n"},
+		{name: "rename_key to with ~", rules: "rules:\n  - action: rename_key\n    key: job\n    to: a~b\n"},
+		{name: "rename_key to with space", rules: "rules:\n  - action: rename_key\n    key: job\n    to: 'a b'\n"},
+		{name: "rename_key to name", rules: "rules:\n  - action: rename_key\n    key: job\n    to: __name__\n"},
+		{name: "drop without key", rules: "rules:\n  - action: drop\n"},
+		{name: "drop with key and regex", rules: "rules:\n  - action: drop\n    key: job\n    regex: ^j\n"},
+		{name: "add without tags", rules: "rules:\n  - action: add\n"},
+		{name: "add invalid key", rules: "rules:\n  - action: add\n    tags: { 'e nv': prod }\n"},
+		{name: "add invalid value", rules: "rules:\n  - action: add\n    tags: { env: '~prod' }\n"},
+		{name: "rewrite_value without key", rules: "rules:\n  - action: rewrite_value\n    regex: a\n"},
+		{name: "rename_name invalid regex", rules: "rules:\n  - action: rename_name\n    regex: '('\n"},
+		{name: "invalid name regex", rules: "rules:\n  - action: drop\n    key: job\n    name: '('\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.rules))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package tags
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Op is a seriesByTag expression operator
+type Op uint8
+
+const (
+	OpEq       Op = iota // tag=value
+	OpNe                 // tag!=value
+	OpMatch              // tag=~regex
+	OpNotMatch           // tag!=~regex
+)
+
+var opStrings = []string{"=", "!=", "=~", "!=~"}
+
+func (o Op) String() string {
+	if int(o) < len(opStrings) {
+		return opStrings[o]
+	}
+	return fmt.Sprintf("Op(%d)", o)
+}
+
+// TagExpr is a seriesByTag expression, like name=cpu, env!=test, host=~dc1-.*
+type TagExpr struct {
+	Key   string // name is replaced with __name__
+	Op    Op
+	Value string
+	re    *regexp.Regexp
+}
+
+// ParseTagExpr parse seriesByTag expression (without quotes)
+func ParseTagExpr(s string) (TagExpr, error) {
+	var e TagExpr
+	n := strings.IndexAny(s, "!=")
+	if n == -1 {
+		return e, fmt.Errorf("invalid tag expression '%s': operator not found", s)
+	}
+	e.Key = strings.TrimSpace(s[:n])
+	op := s[n:]
+	switch {
+	case strings.HasPrefix(op, "!=~"):
+		e.Op, e.Value = OpNotMatch, op[3:]
+	case strings.HasPrefix(op, "!="):
+		e.Op, e.Value = OpNe, op[2:]
+	case strings.HasPrefix(op, "=~"):
+		e.Op, e.Value = OpMatch, op[2:]
+	case op[0] == '=':
+		e.Op, e.Value = OpEq, op[1:]
+	default:
+		return e, fmt.Errorf("invalid tag expression '%s': invalid operator", s)
+	}
+	if e.Key == "" {
+		return e, fmt.Errorf("invalid tag expression '%s': %w", s, ErrEmptyKey)
+	}
+	if e.Key == "name" {
+		e.Key = "__name__"
+	}
+	if e.Op == OpMatch || e.Op == OpNotMatch {
+		// like graphite, regex is anchored at value start
+		re, err := regexp.Compile("^(?:" + e.Value + ")")
+		if err != nil {
+			return e, fmt.Errorf("invalid tag expression '%s': %w", s, err)
+		}
+		e.re = re
+	}
+	return e, nil
+}
+
+// MatchValue report whether tag value matches expression (missing tag is matched as empty value)
+func (e *TagExpr) MatchValue(value string) bool {
+	switch e.Op {
+	case OpEq:
+		return value == e.Value
+	case OpNe:
+		return value != e.Value
+	case OpMatch:
+		return e.re.MatchString(value)
+	case OpNotMatch:
+		return !e.re.MatchString(value)
+	}
+	return false
+}
+
+// MatchEmpty report whether expression matches metrics without tag
+func (e *TagExpr) MatchEmpty() bool {
+	return e.MatchValue("")
+}
+
+// Match report whether tags list (k=v, as returned by TagsParse) matches expression
+func (e *TagExpr) Match(tags []string) bool {
+	value, _ := TagValue(tags, e.Key)
+	return e.MatchValue(value)
+}
+
+func (e *TagExpr) String() string {
+	key := e.Key
+	if key == "__name__" {
+		key = "name"
+	}
+	return key + e.Op.String() + e.Value
+}
+
+// TagValue return tag value from tags list (k=v, as returned by TagsParse)
+func TagValue(tags []string, key string) (string, bool) {
+	for _, tag := range tags {
+		if len(tag) > len(key) && tag[len(key)] == '=' && strings.HasPrefix(tag, key) {
+			return tag[len(key)+1:], true
+		}
+	}
+	return "", false
+}
+
+// SeriesByTag is a list of tag expressions (all must be matched)
+type SeriesByTag []TagExpr
+
+// ParseSeriesByTag parse seriesByTag('name=cpu', 'env!=test') or quoted expressions list without function name
+// or single unquoted expression. Like graphite, at least one expression must not match empty value.
+func ParseSeriesByTag(s string) (SeriesByTag, error) {
+	query := strings.TrimSpace(s)
+	if strings.HasPrefix(query, "seriesByTag(") {
+		if !strings.HasSuffix(query, ")") {
+			return nil, fmt.Errorf("invalid seriesByTag '%s': unclosed '('", s)
+		}
+		query = query[len("seriesByTag(") : len(query)-1]
+	}
+
+	var args []string
+	if query != "" && query[0] != '\'' && query[0] != '"' {
+		args = []string{query}
+	} else {
+		for query = strings.TrimSpace(query); query != ""; {
+			quote := query[0]
+			if quote != '\'' && quote != '"' {
+				return nil, fmt.Errorf("invalid seriesByTag '%s': unquoted expression", s)
+			}
+			end := strings.IndexByte(query[1:], quote)
+			if end == -1 {
+				return nil, fmt.Errorf("invalid seriesByTag '%s': unclosed quote", s)
+			}
+			args = append(args, query[1:end+1])
+			query = strings.TrimSpace(query[end+2:])
+			if query != "" {
+				if query[0] != ',' {
+					return nil, fmt.Errorf("invalid seriesByTag '%s': ',' expected", s)
+				}
+				query = strings.TrimSpace(query[1:])
+			}
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("invalid seriesByTag '%s': no expressions", s)
+	}
+
+	exprs := make(SeriesByTag, 0, len(args))
+	matchEmpty := true
+	for _, arg := range args {
+		e, err := ParseTagExpr(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !e.MatchEmpty() {
+			matchEmpty = false
+		}
+		exprs = append(exprs, e)
+	}
+	if matchEmpty {
+		return nil, fmt.Errorf("invalid seriesByTag '%s': at least one expression must not match empty value", s)
+	}
+	return exprs, nil
+}
+
+// Match report whether tags list (k=v, as returned by TagsParse) matches all expressions
+func (s SeriesByTag) Match(tags []string) bool {
+	for i := range s {
+		if !s[i].Match(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s SeriesByTag) String() string {
+	var sb strings.Builder
+	sb.WriteString("seriesByTag(")
+	for i := range s {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteByte('\'')
+		sb.WriteString(s[i].String())
+		sb.WriteByte('\'')
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
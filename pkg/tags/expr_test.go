@@ -0,0 +1,94 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagExpr(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    TagExpr
+		wantErr bool
+	}{
+		{expr: "name=cpu", want: TagExpr{Key: "__name__", Op: OpEq, Value: "cpu"}},
+		{expr: "env!=test", want: TagExpr{Key: "env", Op: OpNe, Value: "test"}},
+		{expr: "host=~dc1-.*", want: TagExpr{Key: "host", Op: OpMatch, Value: "dc1-.*"}},
+		{expr: "host!=~dc1-.*", want: TagExpr{Key: "host", Op: OpNotMatch, Value: "dc1-.*"}},
+		{expr: "env=", want: TagExpr{Key: "env", Op: OpEq, Value: ""}},
+		{expr: "q=a=b", want: TagExpr{Key: "q", Op: OpEq, Value: "a=b"}},
+		{expr: "env", wantErr: true},
+		{expr: "=cpu", wantErr: true},
+		{expr: "env!test", wantErr: true},
+		{expr: "host=~(", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := ParseTagExpr(tt.expr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Key, e.Key)
+			assert.Equal(t, tt.want.Op, e.Op)
+			assert.Equal(t, tt.want.Value, e.Value)
+			assert.Equal(t, tt.expr, e.String())
+		})
+	}
+}
+
+func TestSeriesByTagMatch(t *testing.T) {
+	_, list, err := TagsParse("cpu;env=prod;host=dc1-h1")
+	require.NoError(t, err)
+
+	tests := []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{query: "name=cpu", want: true},
+		{query: "seriesByTag('name=cpu')", want: true},
+		{query: "seriesByTag('name=cpu', 'env!=test')", want: true},
+		{query: `seriesByTag("name=cpu","env=test")`, want: false},
+		{query: "'host=~dc1-.*','env=prod'", want: true},
+		{query: "seriesByTag('host=~h1')", want: false},
+		{query: "seriesByTag('host!=~dc2', 'name=cpu')", want: true},
+		{query: "seriesByTag('name=cpu', 'dc=')", want: true},
+		{query: "seriesByTag('name=cpu', 'dc!=')", want: false},
+		{query: "seriesByTag('name=cpu', 'dc=~.*')", want: true},
+		{query: "seriesByTag('name=cpu', 'dc!=prod')", want: true},
+		{query: "seriesByTag('dc=')", wantErr: true},
+		{query: "seriesByTag('env!=test')", wantErr: true},
+		{query: "seriesByTag()", wantErr: true},
+		{query: "seriesByTag('name=cpu'", wantErr: true},
+		{query: "seriesByTag('name=cpu' 'env=prod')", wantErr: true},
+		{query: "seriesByTag('name=cpu', env=prod)", wantErr: true},
+		{query: "seriesByTag('name=cpu)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			s, err := ParseSeriesByTag(tt.query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Match(list))
+		})
+	}
+}
+
+func TestTagValue(t *testing.T) {
+	list := []string{"__name__=cpu", "env=prod", "envx=dev", "e="}
+	v, ok := TagValue(list, "env")
+	assert.True(t, ok)
+	assert.Equal(t, "prod", v)
+	v, ok = TagValue(list, "e")
+	assert.True(t, ok)
+	assert.Equal(t, "", v)
+	_, ok = TagValue(list, "en")
+	assert.False(t, ok)
+}
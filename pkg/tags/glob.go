@@ -0,0 +1,95 @@
+package tags
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Glob is a compiled graphite glob pattern, like cpu.*.load{1,5}
+type Glob struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+// GlobToRegexp convert graphite glob pattern to anchored regular expression.
+// Supported: * (any chars in node), ? (any char in node), [...] (chars class), {a,b} (alternatives).
+func GlobToRegexp(pattern string) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(pattern) + 8)
+	sb.WriteByte('^')
+	inBraces := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			sb.WriteString("[^.]*")
+		case '?':
+			sb.WriteString("[^.]")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				return "", fmt.Errorf("unclosed '[' in glob '%s'", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if class == "" {
+				return "", fmt.Errorf("empty '[]' in glob '%s'", pattern)
+			}
+			sb.WriteByte('[')
+			if class[0] == '!' {
+				sb.WriteByte('^')
+				class = class[1:]
+			}
+			sb.WriteString(strings.ReplaceAll(class, `\`, `\\`))
+			sb.WriteByte(']')
+			i += end + 1
+		case '{':
+			if inBraces {
+				return "", fmt.Errorf("nested '{' in glob '%s'", pattern)
+			}
+			inBraces = true
+			sb.WriteString("(?:")
+		case '}':
+			if !inBraces {
+				return "", fmt.Errorf("unexpected '}' in glob '%s'", pattern)
+			}
+			inBraces = false
+			sb.WriteByte(')')
+		case ',':
+			if inBraces {
+				sb.WriteByte('|')
+			} else {
+				sb.WriteByte(c)
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	if inBraces {
+		return "", fmt.Errorf("unclosed '{' in glob '%s'", pattern)
+	}
+	sb.WriteByte('$')
+	return sb.String(), nil
+}
+
+// CompileGlob compile graphite glob pattern
+func CompileGlob(pattern string) (*Glob, error) {
+	expr, err := GlobToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob '%s': %w", pattern, err)
+	}
+	return &Glob{pattern: pattern, re: re}, nil
+}
+
+// Match report whether path matches glob
+func (g *Glob) Match(path string) bool {
+	return g.re.MatchString(path)
+}
+
+func (g *Glob) String() string {
+	return g.pattern
+}
@@ -0,0 +1,69 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{
+			pattern: "cpu.*.load{1,5}",
+			match:   []string{"cpu.h1.load1", "cpu.h2.load5", "cpu..load1"},
+			noMatch: []string{"cpu.h1.load15", "cpu.h1.h2.load1", "cpu.h1.load", "xcpu.h1.load1"},
+		},
+		{
+			pattern: "cpu.h?.load",
+			match:   []string{"cpu.h1.load"},
+			noMatch: []string{"cpu.h.load", "cpu.h10.load", "cpu.h..load"},
+		},
+		{
+			pattern: "disk.sd[a-c].used",
+			match:   []string{"disk.sda.used", "disk.sdc.used"},
+			noMatch: []string{"disk.sdd.used", "disk.sd.used"},
+		},
+		{
+			pattern: "disk.sd[!a].used",
+			match:   []string{"disk.sdb.used"},
+			noMatch: []string{"disk.sda.used"},
+		},
+		{
+			pattern: "a+b.(c)|d",
+			match:   []string{"a+b.(c)|d"},
+			noMatch: []string{"aab.c", "d"},
+		},
+		{
+			pattern: "cpu.{user,sys}_time,x",
+			match:   []string{"cpu.user_time,x", "cpu.sys_time,x"},
+			noMatch: []string{"cpu.user_time"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			g, err := CompileGlob(tt.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, tt.pattern, g.String())
+			for _, path := range tt.match {
+				assert.True(t, g.Match(path), path)
+			}
+			for _, path := range tt.noMatch {
+				assert.False(t, g.Match(path), path)
+			}
+		})
+	}
+}
+
+func TestGlobErrors(t *testing.T) {
+	for _, pattern := range []string{"cpu.{a,b", "cpu.a}", "cpu.{a,{b}}", "cpu.[ab", "cpu.[]"} {
+		t.Run(pattern, func(t *testing.T) {
+			_, err := CompileGlob(pattern)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package tags
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Mode is a tagged metric path canonicalization mode
+type Mode uint8
+
+const (
+	ModeNatural  Mode = iota // natural sort order, no escaping (TagsParse)
+	ModeGraphite             // carbon-clickhouse compatible (TagsParseGraphite)
+)
+
+var modeStrings = []string{"natural", "graphite"}
+
+func (m *Mode) Set(value string) error {
+	for i, s := range modeStrings {
+		if s == value {
+			*m = Mode(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid tags mode: %s, must be one of %v", value, modeStrings)
+}
+
+func (m Mode) String() string {
+	if int(m) < len(modeStrings) {
+		return modeStrings[m]
+	}
+	return fmt.Sprintf("Mode(%d)", m)
+}
+
+func (m *Mode) Type() string {
+	return "tags_mode"
+}
+
+// ParseFunc parse tagged metric into path and tags list
+type ParseFunc func(metric string) (string, []string, error)
+
+// Parser return parse function for mode
+func (m Mode) Parser() ParseFunc {
+	if m == ModeGraphite {
+		return TagsParseGraphite
+	}
+	return TagsParse
+}
+
+// ReusableParser return parse function for mode, which may reuse results buffers (natural mode use TagsParser),
+// so path and tags are valid until next call. It's for encoders, which copy rows data
+// (not safe for concurrent use).
+func (m Mode) ReusableParser() ParseFunc {
+	if m == ModeGraphite {
+		return TagsParseGraphite
+	}
+	p := NewTagsParser()
+	return func(metric string) (string, []string, error) {
+		if err := p.Parse(metric); err != nil {
+			return "", nil, err
+		}
+		return p.Path(), p.Tags(), nil
+	}
+}
+
+// parseGraphite split metric like carbon-clickhouse: last value of duplicate keys wins, empty value is allowed
+func parseGraphite(metric string) (string, []Tag, error) {
+	name, args, found := strings.Cut(metric, ";")
+	if name == "" {
+		return "", nil, fmt.Errorf("cannot parse path '%s', no metric found", metric)
+	}
+	if !found {
+		return name, nil, nil
+	}
+	tags := make([]Tag, 0, 12)
+	for {
+		var segment string
+		segment, args, found = strings.Cut(args, ";")
+		key, value, ok := strings.Cut(segment, "=")
+		if !ok || key == "" {
+			return name, nil, fmt.Errorf("cannot parse path '%s', invalid segment '%s'", metric, segment)
+		}
+		dup := false
+		for i := range tags {
+			if tags[i].Key == key {
+				tags[i].Value = value
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			tags = append(tags, Tag{Key: key, Value: value})
+		}
+		if !found {
+			break
+		}
+	}
+	// tags are sorted by escaped key (with '=' suffix), like carbon-clickhouse
+	sort.Slice(tags, func(i, j int) bool {
+		return url.QueryEscape(tags[i].Key)+"=" < url.QueryEscape(tags[j].Key)+"="
+	})
+	return name, tags, nil
+}
+
+func graphitePath(name string, tags []Tag) string {
+	var sb strings.Builder
+	sb.WriteString(url.PathEscape(name))
+	for i, tag := range tags {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(tag.Key))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(tag.Value))
+	}
+	return sb.String()
+}
+
+// GraphitePath return carbon-clickhouse canonical path (name?k1=v1&k2=v2) for metric,
+// name is escaped with url.PathEscape, tags keys and values with url.QueryEscape.
+// Plain metric is returned as is.
+func GraphitePath(metric string) (string, error) {
+	if strings.IndexByte(metric, ';') == -1 {
+		return metric, nil
+	}
+	name, tags, err := parseGraphite(metric)
+	if err != nil {
+		return "", err
+	}
+	return graphitePath(name, tags), nil
+}
+
+// TagsParseGraphite is a TagsParse with carbon-clickhouse compatible path.
+// Tags list is unescaped, __name__ is first, other tags are in path order.
+func TagsParseGraphite(metric string) (string, []string, error) {
+	name, tags, err := parseGraphite(metric)
+	if err != nil {
+		return name, nil, err
+	}
+	if len(tags) == 0 {
+		return name, nil, fmt.Errorf("incomplete tags in '%s'", metric)
+	}
+	list := make([]string, 0, len(tags)+1)
+	list = append(list, "__name__="+name)
+	for _, tag := range tags {
+		list = append(list, tag.Key+"="+tag.Value)
+	}
+	return graphitePath(name, tags), list, nil
+}
@@ -0,0 +1,117 @@
+package tags
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphitePathGolden(t *testing.T) {
+	f, err := os.Open("testdata/graphite_path.golden")
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	n := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		metric, want, found := strings.Cut(line, "\t")
+		require.True(t, found, line)
+		n++
+		t.Run(metric, func(t *testing.T) {
+			path, err := GraphitePath(metric)
+			if want == "" {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, want, path)
+			}
+		})
+	}
+	require.NoError(t, scanner.Err())
+	assert.NotZero(t, n)
+}
+
+func TestTagsParseGraphite(t *testing.T) {
+	tests := []struct {
+		metric   string
+		wantPath string
+		wantTags []string
+		wantErr  bool
+	}{
+		{
+			metric:   "cpu_util;fqdn=asd;dc=qwe;instance=10.33.10.10:9100;job=node",
+			wantPath: "cpu_util?dc=qwe&fqdn=asd&instance=10.33.10.10%3A9100&job=node",
+			wantTags: []string{"__name__=cpu_util", "dc=qwe", "fqdn=asd", "instance=10.33.10.10:9100", "job=node"},
+		},
+		{
+			metric:   "cpu;host=a9;k10=1;k9=2;host=a10",
+			wantPath: "cpu?host=a10&k10=1&k9=2",
+			wantTags: []string{"__name__=cpu", "host=a10", "k10=1", "k9=2"},
+		},
+		{
+			metric:   "cpu;Env=prod;dc=eu west",
+			wantPath: "cpu?Env=prod&dc=eu+west",
+			wantTags: []string{"__name__=cpu", "Env=prod", "dc=eu west"},
+		},
+		{metric: "cpu", wantErr: true},
+		{metric: "cpu;", wantErr: true},
+		{metric: "cpu;dc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			path, tags, err := TagsParseGraphite(tt.metric)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPath, path)
+			assert.Equal(t, tt.wantTags, tags)
+		})
+	}
+}
+
+func TestModeParser(t *testing.T) {
+	metric := "cpu;k9=1;k10=2"
+
+	var mode Mode
+	assert.Equal(t, "natural", mode.String())
+	path, _, err := mode.Parser()(metric)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu?k9=1&k10=2", path)
+
+	require.NoError(t, mode.Set("graphite"))
+	assert.Equal(t, ModeGraphite, mode)
+	path, _, err = mode.Parser()(metric)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu?k10=2&k9=1", path)
+
+	assert.Error(t, mode.Set("carbon"))
+}
+
+func TestModeReusableParser(t *testing.T) {
+	for _, mode := range []Mode{ModeNatural, ModeGraphite} {
+		t.Run(mode.String(), func(t *testing.T) {
+			parse := mode.ReusableParser()
+			for _, metric := range []string{"cpu;k9=1;k10=2", "mem;b=2;a=1", "cpu;", "disk;a=1"} {
+				wantPath, wantTags, wantErr := mode.Parser()(metric)
+				path, tags, err := parse(metric)
+				if wantErr != nil {
+					assert.Error(t, err, metric)
+					continue
+				}
+				require.NoError(t, err, metric)
+				assert.Equal(t, wantPath, path)
+				assert.Equal(t, wantTags, tags)
+			}
+		})
+	}
+}
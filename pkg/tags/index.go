@@ -0,0 +1,127 @@
+package tags
+
+import (
+	"sort"
+	"strings"
+)
+
+// Index is an in-memory tagged metrics index with postings by tag (like Tag1 rows in tagged table),
+// used for evaluate seriesByTag queries offline
+type Index struct {
+	parse    ParseFunc
+	paths    []string                       // series paths
+	tags     [][]string                     // series tags lists (k=v)
+	ids      map[string]uint32              // path -> series id
+	postings map[string]map[string][]uint32 // key -> value -> series ids (ascending)
+}
+
+// NewIndex create index with metrics parser (TagsParse if nil)
+func NewIndex(parse ParseFunc) *Index {
+	if parse == nil {
+		parse = TagsParse
+	}
+	return &Index{
+		parse:    parse,
+		ids:      make(map[string]uint32),
+		postings: make(map[string]map[string][]uint32),
+	}
+}
+
+// Add parse tagged metric and add it to index (duplicate series are skipped)
+func (idx *Index) Add(metric string) error {
+	path, tags, err := idx.parse(metric)
+	if err != nil {
+		return err
+	}
+	if _, ok := idx.ids[path]; ok {
+		return nil
+	}
+	id := uint32(len(idx.paths))
+	idx.ids[path] = id
+	idx.paths = append(idx.paths, path)
+	idx.tags = append(idx.tags, tags)
+	for _, tag := range tags {
+		key, value, _ := strings.Cut(tag, "=")
+		values, ok := idx.postings[key]
+		if !ok {
+			values = make(map[string][]uint32)
+			idx.postings[key] = values
+		}
+		values[value] = append(values[value], id)
+	}
+	return nil
+}
+
+// Len return series count
+func (idx *Index) Len() int {
+	return len(idx.paths)
+}
+
+// Path return canonical path for metric (as stored in index)
+func (idx *Index) Path(metric string) (string, error) {
+	path, _, err := idx.parse(metric)
+	return path, err
+}
+
+// Query evaluate seriesByTag expressions and return sorted matched paths
+func (idx *Index) Query(s SeriesByTag) []string {
+	// primary expression select candidates from postings, other expressions are checked on series tags
+	primary := -1
+	for i := range s {
+		if !s[i].MatchEmpty() {
+			if primary == -1 || (s[i].Op == OpEq && s[primary].Op != OpEq) {
+				primary = i
+			}
+		}
+	}
+	if primary == -1 {
+		return nil
+	}
+
+	e := &s[primary]
+	var candidates []uint32
+	if e.Op == OpEq {
+		candidates = idx.postings[e.Key][e.Value]
+	} else {
+		for value, ids := range idx.postings[e.Key] {
+			if e.MatchValue(value) {
+				candidates = append(candidates, ids...)
+			}
+		}
+		// series with duplicate keys can be matched by several values
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+	}
+
+	paths := make([]string, 0, len(candidates))
+	for i, id := range candidates {
+		if i > 0 && candidates[i-1] == id {
+			continue
+		}
+		if s.Match(idx.tags[id]) {
+			paths = append(paths, idx.paths[id])
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Diff compare sorted paths lists and return missing (only in expected) and unexpected (only in actual) paths
+func Diff(expected, actual []string) (missing, unexpected []string) {
+	i, j := 0, 0
+	for i < len(expected) && j < len(actual) {
+		switch {
+		case expected[i] == actual[j]:
+			i++
+			j++
+		case expected[i] < actual[j]:
+			missing = append(missing, expected[i])
+			i++
+		default:
+			unexpected = append(unexpected, actual[j])
+			j++
+		}
+	}
+	missing = append(missing, expected[i:]...)
+	unexpected = append(unexpected, actual[j:]...)
+	return missing, unexpected
+}
@@ -0,0 +1,68 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexQuery(t *testing.T) {
+	idx := NewIndex(nil)
+	for _, metric := range []string{
+		"cpu;env=prod;host=dc1-h1",
+		"cpu;env=prod;host=dc1-h2",
+		"cpu;env=test;host=dc2-h1",
+		"cpu;host=dc2-h2",
+		"mem;env=prod;host=dc1-h1",
+		"mem;env=prod;host=dc1-h1", // duplicate
+		"disk;dup=a;dup=b",
+	} {
+		require.NoError(t, idx.Add(metric))
+	}
+	assert.Equal(t, 6, idx.Len())
+	assert.Error(t, idx.Add("cpu;env"))
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "name=cpu", want: []string{"cpu?env=prod&host=dc1-h1", "cpu?env=prod&host=dc1-h2", "cpu?env=test&host=dc2-h1", "cpu?host=dc2-h2"}},
+		{query: "seriesByTag('name=cpu', 'env!=test')", want: []string{"cpu?env=prod&host=dc1-h1", "cpu?env=prod&host=dc1-h2", "cpu?host=dc2-h2"}},
+		{query: "seriesByTag('name=cpu', 'env=')", want: []string{"cpu?host=dc2-h2"}},
+		{query: "seriesByTag('host=~dc1-')", want: []string{"cpu?env=prod&host=dc1-h1", "cpu?env=prod&host=dc1-h2", "mem?env=prod&host=dc1-h1"}},
+		{query: "seriesByTag('env!=', 'host!=~dc1')", want: []string{"cpu?env=test&host=dc2-h1"}},
+		{query: "seriesByTag('name=~cpu|mem', 'host=dc1-h1')", want: []string{"cpu?env=prod&host=dc1-h1", "mem?env=prod&host=dc1-h1"}},
+		{query: "seriesByTag('name=net')", want: []string{}},
+		{query: "seriesByTag('dup=~a|b')", want: []string{"disk?dup=a&dup=b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			s, err := ParseSeriesByTag(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, idx.Query(s))
+		})
+	}
+
+	path, err := idx.Path("cpu;host=dc2-h2")
+	require.NoError(t, err)
+	assert.Equal(t, "cpu?host=dc2-h2", path)
+}
+
+func TestIndexQueryGraphite(t *testing.T) {
+	idx := NewIndex(TagsParseGraphite)
+	require.NoError(t, idx.Add("cpu;k9=1;k10=a b"))
+	s, err := ParseSeriesByTag("seriesByTag('k10=a b')")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu?k10=a+b&k9=1"}, idx.Query(s))
+}
+
+func TestDiff(t *testing.T) {
+	missing, unexpected := Diff([]string{"a", "b", "d", "e"}, []string{"b", "c", "e", "f"})
+	assert.Equal(t, []string{"a", "d"}, missing)
+	assert.Equal(t, []string{"c", "f"}, unexpected)
+
+	missing, unexpected = Diff([]string{"a"}, []string{"a"})
+	assert.Empty(t, missing)
+	assert.Empty(t, unexpected)
+}
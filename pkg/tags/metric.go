@@ -0,0 +1,95 @@
+package tags
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tag is a metric tag
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// Metric is a parsed metric (name;k1=v1;k2=v2), plain metric has no tags
+type Metric struct {
+	Name string
+	Tags []Tag // in metric order
+}
+
+// ParseMetric split metric into name and tags (order and duplicate keys are kept)
+func ParseMetric(metric string) (Metric, error) {
+	name, args, found := strings.Cut(metric, ";")
+	m := Metric{Name: name}
+	if !found {
+		return m, nil
+	}
+	m.Tags = make([]Tag, 0, strings.Count(args, ";")+1)
+	for args != "" {
+		var segment string
+		segment, args, _ = strings.Cut(args, ";")
+		key, value, ok := strings.Cut(segment, "=")
+		if !ok {
+			return m, fmt.Errorf("incomplete tags in '%s'", metric)
+		}
+		m.Tags = append(m.Tags, Tag{Key: key, Value: value})
+	}
+	return m, nil
+}
+
+// Get return value of first tag with key
+func (m *Metric) Get(key string) (string, bool) {
+	for i := range m.Tags {
+		if m.Tags[i].Key == key {
+			return m.Tags[i].Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replace value of tags with key or append new tag
+func (m *Metric) Set(key, value string) {
+	found := false
+	for i := range m.Tags {
+		if m.Tags[i].Key == key {
+			m.Tags[i].Value = value
+			found = true
+		}
+	}
+	if !found {
+		m.Tags = append(m.Tags, Tag{Key: key, Value: value})
+	}
+}
+
+// DeleteFunc delete tags, matched by f
+func (m *Metric) DeleteFunc(f func(tag Tag) bool) {
+	n := 0
+	for _, tag := range m.Tags {
+		if !f(tag) {
+			m.Tags[n] = tag
+			n++
+		}
+	}
+	m.Tags = m.Tags[:n]
+}
+
+// String return metric in graphite format (name;k1=v1;k2=v2)
+func (m *Metric) String() string {
+	if len(m.Tags) == 0 {
+		return m.Name
+	}
+	n := len(m.Name)
+	for _, tag := range m.Tags {
+		n += len(tag.Key) + len(tag.Value) + 2
+	}
+	var sb strings.Builder
+	sb.Grow(n)
+	sb.WriteString(m.Name)
+	for _, tag := range m.Tags {
+		sb.WriteByte(';')
+		sb.WriteString(tag.Key)
+		sb.WriteByte('=')
+		sb.WriteString(tag.Value)
+	}
+	return sb.String()
+}
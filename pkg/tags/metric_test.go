@@ -0,0 +1,59 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetric(t *testing.T) {
+	tests := []struct {
+		metric  string
+		want    Metric
+		wantStr string
+		wantErr bool
+	}{
+		{metric: "cpu", want: Metric{Name: "cpu"}},
+		{metric: "cpu;", want: Metric{Name: "cpu", Tags: []Tag{}}, wantStr: "cpu"},
+		{
+			metric: "cpu;dc=qwe;host=h1;dc=asd",
+			want:   Metric{Name: "cpu", Tags: []Tag{{"dc", "qwe"}, {"host", "h1"}, {"dc", "asd"}}},
+		},
+		{metric: "cpu;dc=a=b", want: Metric{Name: "cpu", Tags: []Tag{{"dc", "a=b"}}}},
+		{metric: "cpu;dc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			m, err := ParseMetric(tt.metric)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m)
+			if tt.wantStr == "" {
+				tt.wantStr = tt.metric
+			}
+			assert.Equal(t, tt.wantStr, m.String())
+		})
+	}
+}
+
+func TestMetricModify(t *testing.T) {
+	m, err := ParseMetric("cpu;dc=qwe;host=h1;dc=asd")
+	require.NoError(t, err)
+
+	v, ok := m.Get("dc")
+	assert.True(t, ok)
+	assert.Equal(t, "qwe", v)
+	_, ok = m.Get("env")
+	assert.False(t, ok)
+
+	m.Set("dc", "eu")
+	m.Set("env", "prod")
+	assert.Equal(t, "cpu;dc=eu;host=h1;dc=eu;env=prod", m.String())
+
+	m.DeleteFunc(func(tag Tag) bool { return tag.Key == "dc" })
+	assert.Equal(t, "cpu;host=h1;env=prod", m.String())
+}
@@ -0,0 +1,115 @@
+package tags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/maruel/natural"
+	"github.com/msaf1980/go-stringutils"
+)
+
+const nameTagPrefix = "__name__="
+
+// TagsParser is a reusable low-allocation TagsParse variant.
+// Tags are substrings of parsed metric, name tag and path are built in reused buffers,
+// so results are valid until next Parse call.
+type TagsParser struct {
+	metric  string
+	nameLen int
+	nameTag []byte
+	path    []byte
+	tags    []string
+}
+
+// NewTagsParser create parser
+func NewTagsParser() *TagsParser {
+	return &TagsParser{
+		nameTag: make([]byte, 0, 128),
+		path:    make([]byte, 0, 512),
+		tags:    make([]string, 0, 16),
+	}
+}
+
+func (p *TagsParser) reset(metric string) {
+	p.metric = metric
+	p.nameLen = 0
+	p.nameTag = p.nameTag[:0]
+	p.path = p.path[:0]
+	p.tags = p.tags[:0]
+}
+
+// Parse parse tagged metric (like TagsParse)
+func (p *TagsParser) Parse(metric string) error {
+	p.reset(metric)
+	delim := strings.IndexByte(metric, ';')
+	if delim == -1 || delim == len(metric)-1 {
+		return fmt.Errorf("incomplete tags in '%s'", metric)
+	}
+	name := metric[:delim]
+	p.nameLen = delim
+	p.nameTag = append(p.nameTag, nameTagPrefix...)
+	p.nameTag = append(p.nameTag, name...)
+	p.tags = append(p.tags, stringutils.UnsafeString(p.nameTag))
+
+	args := metric[delim+1:]
+	for args != "" {
+		tag := args
+		if delim = strings.IndexByte(args, ';'); delim == -1 {
+			args = ""
+		} else {
+			tag = args[:delim]
+			args = args[delim+1:]
+		}
+		if strings.IndexByte(tag, '=') == -1 {
+			p.reset(metric)
+			return fmt.Errorf("incomplete tags in '%s'", metric)
+		}
+		p.tags = append(p.tags, tag)
+	}
+
+	p.sort()
+
+	p.path = append(p.path, name...)
+	p.path = append(p.path, '?')
+	for i, tag := range p.tags[1:] {
+		if i > 0 {
+			p.path = append(p.path, '&')
+		}
+		p.path = append(p.path, tag...)
+	}
+	return nil
+}
+
+// sort sort tags in natural order (insertion sort for usual tags count, without allocations)
+func (p *TagsParser) sort() {
+	if len(p.tags) > 32 {
+		sort.Sort(natural.StringSlice(p.tags))
+		return
+	}
+	for i := 1; i < len(p.tags); i++ {
+		for j := i; j > 0 && natural.Less(p.tags[j], p.tags[j-1]); j-- {
+			p.tags[j], p.tags[j-1] = p.tags[j-1], p.tags[j]
+		}
+	}
+}
+
+// Metric return parsed metric
+func (p *TagsParser) Metric() string {
+	return p.metric
+}
+
+// Name return metric name
+func (p *TagsParser) Name() string {
+	return p.metric[:p.nameLen]
+}
+
+// Path return canonical path (valid until next Parse)
+func (p *TagsParser) Path() string {
+	return stringutils.UnsafeString(p.path)
+}
+
+// Tags return sorted tags list with __name__ tag (valid until next Parse)
+func (p *TagsParser) Tags() []string {
+	return p.tags
+}
@@ -0,0 +1,105 @@
+package tags
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagsParser(t *testing.T) {
+	metrics := []string{
+		"cpu_util;fqdn=asd;dc=qwe;instance=10.33.10.10_9100;job=node",
+		"cpu_util;dc=qwe;fqdn=asd",
+		"cpu_util;dc=qwe;fqdn=asd;",
+		"cpu_util;host=h10;host=h2",
+		"cpu_util;a=1;A=2;__name__=x",
+		";a=1",
+		"cpu_util;a=",
+		"cpu_util",
+		"cpu_util;",
+		"cpu_util;a",
+		"cpu_util;a=1;;b=2",
+		"cpu_util;a=1;b",
+	}
+	p := NewTagsParser()
+	for _, metric := range metrics {
+		t.Run(metric, func(t *testing.T) {
+			wantPath, wantTags, wantErr := TagsParse(metric)
+			err := p.Parse(metric)
+			if wantErr != nil {
+				assert.EqualError(t, err, wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, metric, p.Metric())
+			assert.Equal(t, wantPath, p.Path())
+			assert.Equal(t, wantTags, p.Tags())
+		})
+	}
+}
+
+func TestTagsParserReuse(t *testing.T) {
+	p := NewTagsParser()
+	require.NoError(t, p.Parse("cpu_util;dc=qwe;fqdn=asd"))
+	assert.Equal(t, "cpu_util?dc=qwe&fqdn=asd", p.Path())
+	assert.Equal(t, "cpu_util", p.Name())
+
+	require.NoError(t, p.Parse("mem;b=2;a=1"))
+	assert.Equal(t, "mem", p.Name())
+	assert.Equal(t, "mem?a=1&b=2", p.Path())
+	assert.Equal(t, []string{"__name__=mem", "a=1", "b=2"}, p.Tags())
+
+	assert.Error(t, p.Parse("mem;b"))
+	assert.Empty(t, p.Tags())
+	assert.Empty(t, p.Path())
+}
+
+func TestTagsParserAllocs(t *testing.T) {
+	metric := "cpu_util;fqdn=asd;dc=qwe;instance=10.33.10.10_9100;job=node"
+	p := NewTagsParser()
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := p.Parse(metric); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func benchmarkTaggedMetrics() []string {
+	metrics := make([]string, 1000)
+	for i := range metrics {
+		metrics[i] = "test.metric.path" + strconv.Itoa(i) + ";host=host" + strconv.Itoa(i) + ";instance=instance;dc=dc1;env=prod;job=node"
+	}
+	return metrics
+}
+
+func BenchmarkTagsParse(b *testing.B) {
+	metrics := benchmarkTaggedMetrics()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, metric := range metrics {
+			if _, _, err := TagsParse(metric); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkTagsParser(b *testing.B) {
+	metrics := benchmarkTaggedMetrics()
+	p := NewTagsParser()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, metric := range metrics {
+			if err := p.Parse(metric); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prometheus conversion errors
+var (
+	ErrPromSyntax        = errors.New("invalid prometheus series")
+	ErrPromName          = errors.New("invalid prometheus metric name")
+	ErrPromLabel         = errors.New("invalid prometheus label name")
+	ErrPromReservedLabel = errors.New("reserved prometheus label")
+)
+
+const hexDigits = "0123456789ABCDEF"
+
+func isPromNameChar(c byte, first bool) bool {
+	return c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
+func isPromLabelChar(c byte, first bool) bool {
+	return c != ':' && isPromNameChar(c, first)
+}
+
+func validPromName(name string, label bool) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if label {
+			if !isPromLabelChar(name[i], i == 0) {
+				return false
+			}
+		} else if !isPromNameChar(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func appendPercent(sb *strings.Builder, c byte) {
+	sb.WriteByte('%')
+	sb.WriteByte(hexDigits[c>>4])
+	sb.WriteByte(hexDigits[c&15])
+}
+
+// escapeName escape prometheus metric name for graphite
+func escapeName(name string) string {
+	if strings.IndexByte(name, ':') == -1 {
+		return name
+	}
+	return strings.ReplaceAll(name, ":", "%3A")
+}
+
+// escapeValue escape prometheus label value for graphite tag value
+func escapeValue(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '%' || c == ';' || c == '\t' || c == '\n' || c == '\r' || (c == '~' && i == 0):
+			if sb.Len() == 0 {
+				sb.Grow(len(value) + 8)
+				sb.WriteString(value[:i])
+			}
+			appendPercent(&sb, c)
+		case sb.Len() > 0:
+			sb.WriteByte(c)
+		}
+	}
+	if sb.Len() == 0 {
+		return value
+	}
+	return sb.String()
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
+
+// unescape decode %XX sequences (invalid sequences are kept as is)
+func unescape(s string) string {
+	if strings.IndexByte(s, '%') == -1 {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			h, ok1 := unhex(s[i+1])
+			l, ok2 := unhex(s[i+2])
+			if ok1 && ok2 {
+				buf = append(buf, h<<4|l)
+				i += 2
+				continue
+			}
+		}
+		buf = append(buf, s[i])
+	}
+	return string(buf)
+}
+
+// isNameKey check for name, _name, __name, ...
+func isNameKey(key string) bool {
+	return strings.TrimLeft(key, "_") == "name" && key != "__name__"
+}
+
+// escapeLabel convert prometheus label name to graphite tag key
+func escapeLabel(label string) string {
+	if isNameKey(label) {
+		return "_" + label
+	}
+	return label
+}
+
+// unescapeKey convert graphite tag key to prometheus label name
+func unescapeKey(key string) string {
+	if isNameKey(key) && key[0] == '_' {
+		return key[1:]
+	}
+	return key
+}
+
+// FromPrometheus convert prometheus series, like name{label="value",...} or {__name__="name",label="value"},
+// to graphite tagged metric (name;label=value;...), labels are sorted:
+//
+//	':' in metric name is escaped as %3A (graphite don't allow it)
+//	'%', ';', '\t', '\n', '\r' and leading '~' in label values are escaped as %XX
+//	labels name, _name, ... are prefixed with '_' (graphite reserve name tag)
+//	labels with empty value are dropped (like in prometheus)
+func FromPrometheus(series string) (string, error) {
+	series = strings.TrimSpace(series)
+	var name string
+	n := strings.IndexByte(series, '{')
+	if n == -1 {
+		name, series = series, ""
+	} else {
+		if series[len(series)-1] != '}' {
+			return "", fmt.Errorf("%w: unclosed '{' in '%s'", ErrPromSyntax, series)
+		}
+		name = strings.TrimSpace(series[:n])
+		series = series[n+1 : len(series)-1]
+	}
+
+	labels := make([]Tag, 0, 8)
+	s := series
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			break
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq == -1 {
+			return "", fmt.Errorf("%w: label without value in '%s'", ErrPromSyntax, series)
+		}
+		label := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+		if s == "" || s[0] != '"' {
+			return "", fmt.Errorf("%w: unquoted label value in '%s'", ErrPromSyntax, series)
+		}
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid label value in '%s'", ErrPromSyntax, series)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid label value in '%s'", ErrPromSyntax, series)
+		}
+		s = strings.TrimLeft(s[len(quoted):], " ")
+		if s != "" {
+			if s[0] != ',' {
+				return "", fmt.Errorf("%w: ',' expected in '%s'", ErrPromSyntax, series)
+			}
+			s = s[1:]
+		}
+
+		if label == "__name__" {
+			if name != "" {
+				return "", fmt.Errorf("%w: duplicate name in '%s'", ErrPromSyntax, series)
+			}
+			name = value
+			continue
+		}
+		if !validPromName(label, true) {
+			return "", fmt.Errorf("%w: '%s'", ErrPromLabel, label)
+		}
+		if strings.HasPrefix(label, "__") {
+			return "", fmt.Errorf("%w: '%s'", ErrPromReservedLabel, label)
+		}
+		if value == "" {
+			continue
+		}
+		labels = append(labels, Tag{Key: escapeLabel(label), Value: escapeValue(value)})
+	}
+	if !validPromName(name, false) {
+		return "", fmt.Errorf("%w: '%s'", ErrPromName, name)
+	}
+	if len(labels) == 0 {
+		return "", fmt.Errorf("%w: no labels in '%s'", ErrPromSyntax, series)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Key < labels[j].Key })
+	for i := 1; i < len(labels); i++ {
+		if labels[i].Key == labels[i-1].Key {
+			return "", fmt.Errorf("%w: duplicate label '%s'", ErrPromSyntax, unescapeKey(labels[i].Key))
+		}
+	}
+	m := Metric{Name: escapeName(name), Tags: labels}
+	return m.String(), nil
+}
+
+// PrometheusTagsParse convert prometheus series and parse it with TagsParse
+func PrometheusTagsParse(series string) (string, []string, error) {
+	metric, err := FromPrometheus(series)
+	if err != nil {
+		return "", nil, err
+	}
+	return TagsParse(metric)
+}
+
+// TagsToPrometheus convert tags list (with __name__=, as returned by TagsParse) to prometheus series
+// (name{label="value",...}, labels are sorted)
+func TagsToPrometheus(tags []string) (string, error) {
+	var name string
+	labels := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			return "", fmt.Errorf("%w: '%s'", ErrIncomplete, tag)
+		}
+		if key == "__name__" {
+			name = unescape(value)
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		label := unescapeKey(key)
+		if !validPromName(label, true) || strings.HasPrefix(label, "__") {
+			return "", fmt.Errorf("%w: '%s'", ErrPromLabel, label)
+		}
+		labels = append(labels, Tag{Key: label, Value: unescape(value)})
+	}
+	if !validPromName(name, false) {
+		return "", fmt.Errorf("%w: '%s'", ErrPromName, name)
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Key < labels[j].Key })
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(label.Key)
+		sb.WriteString(`="`)
+		for j := 0; j < len(label.Value); j++ {
+			switch c := label.Value[j]; c {
+			case '\\':
+				sb.WriteString(`\\`)
+			case '"':
+				sb.WriteString(`\"`)
+			case '\n':
+				sb.WriteString(`\n`)
+			default:
+				sb.WriteByte(c)
+			}
+		}
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String(), nil
+}
+
+// ToPrometheus convert graphite tagged metric (name;label=value;...) to prometheus series
+func ToPrometheus(metric string) (string, error) {
+	_, tags, err := TagsParse(metric)
+	if err != nil {
+		return "", err
+	}
+	return TagsToPrometheus(tags)
+}
@@ -0,0 +1,104 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromPrometheus(t *testing.T) {
+	tests := []struct {
+		series  string
+		want    string
+		wantErr error
+	}{
+		{series: `up{job="node",instance="h1:9100"}`, want: "up;instance=h1:9100;job=node"},
+		{series: `{__name__="up", job="node"}`, want: "up;job=node"},
+		{series: `node:cpu:rate5m{mode="idle"}`, want: "node%3Acpu%3Arate5m;mode=idle"},
+		{series: `http_requests{path="/a;b",q="100%",re="~x",msg="a\"b\\c\nd"}`, want: "http_requests;msg=a\"b\\c%0Ad;path=/a%3Bb;q=100%25;re=%7Ex"},
+		{series: `up{job="node",empty=""}`, want: "up;job=node"},
+		{series: `up{name="n1",_name="n2",job="x"}`, want: "up;__name=n2;_name=n1;job=x"},
+		{series: `up{job="node",}`, want: "up;job=node"},
+		{series: `up`, wantErr: ErrPromSyntax},
+		{series: `up{}`, wantErr: ErrPromSyntax},
+		{series: `up{job="node"`, wantErr: ErrPromSyntax},
+		{series: `up{job=node}`, wantErr: ErrPromSyntax},
+		{series: `up{job}`, wantErr: ErrPromSyntax},
+		{series: `up{job="a" env="b"}`, wantErr: ErrPromSyntax},
+		{series: `up{job="a",job="b"}`, wantErr: ErrPromSyntax},
+		{series: `up{__name__="up",job="a"}`, wantErr: ErrPromSyntax},
+		{series: `1up{job="a"}`, wantErr: ErrPromName},
+		{series: `{job="a"}`, wantErr: ErrPromName},
+		{series: `up{j-b="a"}`, wantErr: ErrPromLabel},
+		{series: `up{a:b="a"}`, wantErr: ErrPromLabel},
+		{series: `up{__meta="a"}`, wantErr: ErrPromReservedLabel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.series, func(t *testing.T) {
+			got, err := FromPrometheus(tt.series)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, Validate(got))
+		})
+	}
+}
+
+func TestPrometheusRoundTrip(t *testing.T) {
+	tests := []struct {
+		series    string
+		canonical string // prometheus canonical form (sorted labels)
+		wantTags  []string
+	}{
+		{
+			series:    `up{job="node",instance="h1:9100"}`,
+			canonical: `up{instance="h1:9100",job="node"}`,
+			wantTags:  []string{"__name__=up", "instance=h1:9100", "job=node"},
+		},
+		{
+			series:    `node:cpu:rate5m{mode="idle",cpu="0"}`,
+			canonical: `node:cpu:rate5m{cpu="0",mode="idle"}`,
+			wantTags:  []string{"__name__=node%3Acpu%3Arate5m", "cpu=0", "mode=idle"},
+		},
+		{
+			series:    `http_requests{path="/a;b",q="100%",re="~x",msg="a\"b\\c\nd"}`,
+			canonical: `http_requests{msg="a\"b\\c\nd",path="/a;b",q="100%",re="~x"}`,
+			wantTags:  []string{"__name__=http_requests", "msg=a\"b\\c%0Ad", "path=/a%3Bb", "q=100%25", "re=%7Ex"},
+		},
+		{
+			series:    `{__name__="up",name="n1",_name="n2"}`,
+			canonical: `up{_name="n2",name="n1"}`,
+			wantTags:  []string{"__name=n2", "__name__=up", "_name=n1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.series, func(t *testing.T) {
+			_, tags, err := PrometheusTagsParse(tt.series)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTags, tags)
+
+			got, err := TagsToPrometheus(tags)
+			require.NoError(t, err)
+			assert.Equal(t, tt.canonical, got)
+
+			metric, err := FromPrometheus(got)
+			require.NoError(t, err)
+			got, err = ToPrometheus(metric)
+			require.NoError(t, err)
+			assert.Equal(t, tt.canonical, got)
+		})
+	}
+}
+
+func TestToPrometheusErrors(t *testing.T) {
+	for _, metric := range []string{"cpu.load;env=prod", "cpu;j-b=a", "cpu;__meta=a", "cpu;env"} {
+		t.Run(metric, func(t *testing.T) {
+			_, err := ToPrometheus(metric)
+			assert.Error(t, err)
+		})
+	}
+}
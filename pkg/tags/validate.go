@@ -0,0 +1,172 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validation errors (graphite tags spec)
+var (
+	ErrNoTags       = errors.New("no tags")
+	ErrEmptyName    = errors.New("empty name")
+	ErrInvalidName  = errors.New("invalid name")
+	ErrIncomplete   = errors.New("tag without '='")
+	ErrEmptyKey     = errors.New("empty tag key")
+	ErrEmptyValue   = errors.New("empty tag value")
+	ErrDuplicateKey = errors.New("duplicate tag key")
+	ErrReservedKey  = errors.New("reserved tag key")
+	ErrInvalidKey   = errors.New("invalid tag key")
+	ErrInvalidValue = errors.New("invalid tag value")
+)
+
+// invalidKeyChars is a chars, not allowed in tag key
+const invalidKeyChars = ";!^=~ "
+
+// TagError describe invalid tag
+type TagError struct {
+	Metric string
+	Tag    string
+	Err    error
+}
+
+func (e *TagError) Error() string {
+	if e.Tag == "" {
+		return fmt.Sprintf("%v in '%s'", e.Err, e.Metric)
+	}
+	return fmt.Sprintf("%v '%s' in '%s'", e.Err, e.Tag, e.Metric)
+}
+
+func (e *TagError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateName check metric name (without ';' and '=', which are tags delimiters)
+func ValidateName(name string) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+	if strings.ContainsAny(name, ";=") {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// ValidateKey check tag key
+func ValidateKey(key string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if strings.ContainsAny(key, invalidKeyChars) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// ValidateValue check tag value
+func ValidateValue(value string) error {
+	if value == "" {
+		return ErrEmptyValue
+	}
+	if value[0] == '~' || strings.IndexByte(value, ';') >= 0 {
+		return ErrInvalidValue
+	}
+	return nil
+}
+
+// Validate check tagged metric (like name;tag1=value1;tag2=value2) by graphite tags spec
+// and return *TagError for first invalid tag
+func Validate(metric string) error {
+	name, args, found := strings.Cut(metric, ";")
+	if name == "" {
+		return &TagError{Metric: metric, Err: ErrEmptyName}
+	}
+	if !found || args == "" {
+		return &TagError{Metric: metric, Err: ErrNoTags}
+	}
+	var keys [16]string
+	seen := keys[:0]
+	for args != "" {
+		var tag string
+		tag, args, _ = strings.Cut(args, ";")
+		key, value, found := strings.Cut(tag, "=")
+		if !found {
+			return &TagError{Metric: metric, Tag: tag, Err: ErrIncomplete}
+		}
+		if err := ValidateKey(key); err != nil {
+			return &TagError{Metric: metric, Tag: tag, Err: err}
+		}
+		if key == "__name__" {
+			// name is set by metric path
+			return &TagError{Metric: metric, Tag: tag, Err: ErrReservedKey}
+		}
+		if err := ValidateValue(value); err != nil {
+			return &TagError{Metric: metric, Tag: tag, Err: err}
+		}
+		for _, k := range seen {
+			if k == key {
+				return &TagError{Metric: metric, Tag: tag, Err: ErrDuplicateKey}
+			}
+		}
+		seen = append(seen, key)
+	}
+	return nil
+}
+
+// Normalize trim spaces in name, tags keys and values, drop tags with empty key or value
+// and collapse duplicate keys (last value wins, tags order is kept).
+// Metric without tags after normalization is returned as plain name.
+// Result is not validated, so use Validate after Normalize.
+func Normalize(metric string) (string, error) {
+	name, args, _ := strings.Cut(metric, ";")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return metric, &TagError{Metric: metric, Err: ErrEmptyName}
+	}
+
+	type tag struct {
+		key   string
+		value string
+	}
+	var buf [16]tag
+	tags := buf[:0]
+	for args != "" {
+		var kv string
+		kv, args, _ = strings.Cut(args, ";")
+		key, value, found := strings.Cut(kv, "=")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key == "" || value == "" {
+			continue
+		}
+		if key == "__name__" {
+			name = value
+			continue
+		}
+		dup := false
+		for i := range tags {
+			if tags[i].key == key {
+				tags[i].value = value
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			tags = append(tags, tag{key: key, value: value})
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(metric))
+	sb.WriteString(name)
+	for _, t := range tags {
+		sb.WriteByte(';')
+		sb.WriteString(t.key)
+		sb.WriteByte('=')
+		sb.WriteString(t.value)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,94 @@
+package tags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		metric  string
+		wantErr error
+		wantTag string
+	}{
+		{metric: "cpu;dc=qwe;host=h1"},
+		{metric: "cpu.load;dc=qwe~;host=h~1;path=a/b?c&d"},
+		{metric: "cpu", wantErr: ErrNoTags},
+		{metric: "cpu;", wantErr: ErrNoTags},
+		{metric: ";dc=qwe", wantErr: ErrEmptyName},
+		{metric: "cpu;dc", wantErr: ErrIncomplete, wantTag: "dc"},
+		{metric: "cpu;dc=qwe;", wantErr: nil},
+		{metric: "cpu;dc=qwe;;host=h1", wantErr: ErrIncomplete, wantTag: ""},
+		{metric: "cpu;=qwe", wantErr: ErrEmptyKey, wantTag: "=qwe"},
+		{metric: "cpu;dc=", wantErr: ErrEmptyValue, wantTag: "dc="},
+		{metric: "cpu;dc=qwe;dc=asd", wantErr: ErrDuplicateKey, wantTag: "dc=asd"},
+		{metric: "cpu;__name__=mem", wantErr: ErrReservedKey, wantTag: "__name__=mem"},
+		{metric: "cpu;d!c=qwe", wantErr: ErrInvalidKey, wantTag: "d!c=qwe"},
+		{metric: "cpu;d^c=qwe", wantErr: ErrInvalidKey, wantTag: "d^c=qwe"},
+		{metric: "cpu;d~c=qwe", wantErr: ErrInvalidKey, wantTag: "d~c=qwe"},
+		{metric: "cpu;d c=qwe", wantErr: ErrInvalidKey, wantTag: "d c=qwe"},
+		{metric: "cpu;dc=q=we", wantErr: nil},
+		{metric: "cpu;dc=~qwe", wantErr: ErrInvalidValue, wantTag: "dc=~qwe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			err := Validate(tt.metric)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			var tagErr *TagError
+			require.ErrorAs(t, err, &tagErr)
+			assert.Equal(t, tt.metric, tagErr.Metric)
+			assert.Equal(t, tt.wantTag, tagErr.Tag)
+		})
+	}
+}
+
+func TestValidateKeyValue(t *testing.T) {
+	assert.NoError(t, ValidateName("cpu.load"))
+	assert.ErrorIs(t, ValidateName(""), ErrEmptyName)
+	assert.ErrorIs(t, ValidateName("cpu;dc"), ErrInvalidName)
+	assert.ErrorIs(t, ValidateName("cpu=1"), ErrInvalidName)
+
+	assert.NoError(t, ValidateKey("dc"))
+	assert.ErrorIs(t, ValidateKey(""), ErrEmptyKey)
+	assert.ErrorIs(t, ValidateKey("d=c"), ErrInvalidKey)
+	assert.ErrorIs(t, ValidateKey("d;c"), ErrInvalidKey)
+
+	assert.NoError(t, ValidateValue("q~we"))
+	assert.ErrorIs(t, ValidateValue(""), ErrEmptyValue)
+	assert.ErrorIs(t, ValidateValue("~qwe"), ErrInvalidValue)
+	assert.ErrorIs(t, ValidateValue("q;we"), ErrInvalidValue)
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		metric  string
+		want    string
+		wantErr error
+	}{
+		{metric: "cpu;dc=qwe;host=h1", want: "cpu;dc=qwe;host=h1"},
+		{metric: " cpu ; dc = qwe ;host= h1 ", want: "cpu;dc=qwe;host=h1"},
+		{metric: "cpu;dc=qwe;host=h1;dc=asd", want: "cpu;dc=asd;host=h1"},
+		{metric: "cpu;dc=;=qwe;host=h1;;env", want: "cpu;host=h1"},
+		{metric: "cpu;dc= ", want: "cpu"},
+		{metric: "cpu;__name__=mem;dc=qwe", want: "mem;dc=qwe"},
+		{metric: "cpu", want: "cpu"},
+		{metric: " ;dc=qwe", wantErr: ErrEmptyName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			got, err := Normalize(tt.metric)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
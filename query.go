@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/msaf1980/carbon-clickhouse-loader/pkg/tags"
+	flag "github.com/spf13/pflag"
+)
+
+func queryUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: %s query [flags] QUERY...\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Index tagged metrics files in memory and print paths, matched by seriesByTag queries,\n")
+	fmt.Fprintf(os.Stderr, "like seriesByTag('name=cpu','env!=test'). With --expected print diff (- missing, + unexpected).\n\n")
+	fs.PrintDefaults()
+}
+
+// readLines read non-empty lines (lines, started with #, are skipped)
+func readLines(r *bufio.Reader, f func(line string) error) error {
+	for {
+		line, err := r.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" && line[0] != '#' {
+			if ferr := f(line); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// readExpected read expected paths (or metrics, converted to paths), return sorted unique paths
+func readExpected(filename string, idx *tags.Index) ([]string, error) {
+	r, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = readLines(r, func(line string) error {
+		if strings.Contains(line, ";") {
+			path, err := idx.Path(line)
+			if err != nil {
+				return err
+			}
+			line = path
+		}
+		paths = append(paths, line)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	n := 0
+	for i, path := range paths {
+		if i == 0 || paths[n-1] != path {
+			paths[n] = path
+			n++
+		}
+	}
+	return paths[:n], nil
+}
+
+// queryMain is a query subcommand, return exit code
+func queryMain(args []string) int {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	fs.Usage = func() { queryUsage(fs) }
+	var fileNames StringSlice
+	fs.VarP(&fileNames, "file", "f", "metrics file")
+	var tagsMode tags.Mode
+	fs.Var(&tagsMode, "tags-mode", "tagged path mode: natural or graphite")
+	expected := fs.String("expected", "", "expected paths (or metrics) file for single query, print diff and exit with 1 on mismatch")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 || len(fileNames) == 0 || (*expected != "" && fs.NArg() != 1) {
+		queryUsage(fs)
+		return 2
+	}
+
+	queries := make([]tags.SeriesByTag, 0, fs.NArg())
+	for _, arg := range fs.Args() {
+		s, err := tags.ParseSeriesByTag(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		queries = append(queries, s)
+	}
+
+	idx := tags.NewIndex(tagsMode.Parser())
+	var invalid int
+	for _, filename := range fileNames {
+		r, err := openFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		err = readLines(r, func(line string) error {
+			if strings.Contains(line, ";") {
+				if err := idx.Add(line); err != nil {
+					invalid++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(os.Stderr, "indexed %d series, invalid %d\n", idx.Len(), invalid)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if *expected != "" {
+		want, err := readExpected(*expected, idx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *expected, err)
+			return 1
+		}
+		got := idx.Query(queries[0])
+		missing, unexpected := tags.Diff(want, got)
+		for _, path := range missing {
+			fmt.Fprintf(out, "- %s\n", path)
+		}
+		for _, path := range unexpected {
+			fmt.Fprintf(out, "+ %s\n", path)
+		}
+		fmt.Fprintf(os.Stderr, "expected %d, got %d, missing %d, unexpected %d\n", len(want), len(got), len(missing), len(unexpected))
+		if len(missing) > 0 || len(unexpected) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	for _, s := range queries {
+		paths := idx.Query(s)
+		if len(queries) > 1 {
+			fmt.Fprintf(out, "# %s: %d\n", s.String(), len(paths))
+		}
+		for _, path := range paths {
+			fmt.Fprintln(out, path)
+		}
+	}
+	return 0
+}